@@ -0,0 +1,205 @@
+// Package astelements exposes go/ast syntax trees as elements, so that Go
+// source can be queried with a path.
+package astelements
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"reflect"
+
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+var (
+	nodeType  = reflect.TypeOf((*ast.Node)(nil)).Elem()
+	posType   = reflect.TypeOf(token.NoPos)
+	tokenType = reflect.TypeOf(token.ILLEGAL)
+)
+
+// element is either a node, named by its type such as FuncDecl, or a field of
+// a node holding other nodes, named by the field such as Body. So
+// //FuncDecl/Body//CallExpr finds the calls within the bodies of functions.
+//
+// The attributes of a node are its fields holding identifiers, tokens,
+// strings, numbers and booleans, along with Filename, Line, Column, EndLine and
+// EndColumn when there's a file set. Fields have no attributes.
+type element struct {
+	fset   *token.FileSet
+	name   string
+	node   ast.Node
+	nodes  []ast.Node
+	id     string
+	parent *element
+}
+
+// MakeElement wraps the node, using the file set for the positions of nodes,
+// which can be nil.
+func MakeElement(fset *token.FileSet, node ast.Node) s.Element {
+	return makeNode(fset, node, nil)
+}
+
+func makeNode(fset *token.FileSet, node ast.Node, parent *element) *element {
+	return &element{
+		fset:   fset,
+		name:   reflect.Indirect(reflect.ValueOf(node)).Type().Name(),
+		node:   node,
+		id:     fmt.Sprintf("%p", node),
+		parent: parent,
+	}
+}
+
+func (e *element) Name() string {
+	return e.name
+}
+
+// ID is the address of the node, followed by the name of the field for
+// fields.
+func (e *element) ID() string {
+	return e.id
+}
+
+func (e *element) Parent() s.Element {
+	if e.parent == nil {
+		return nil
+	}
+	return e.parent
+}
+
+// Node returns the node, or nil for a field.
+func (e *element) Node() ast.Node {
+	return e.node
+}
+
+func (e *element) Children() []s.Element {
+	res := make([]s.Element, 0)
+	if e.node == nil {
+		for _, v := range e.nodes {
+			res = append(res, makeNode(e.fset, v, e))
+		}
+		return res
+	}
+
+	fields(e.node, func(name string, value reflect.Value) {
+		if nodes := children(value); len(nodes) > 0 {
+			res = append(res, &element{
+				fset:   e.fset,
+				name:   name,
+				nodes:  nodes,
+				id:     e.id + "/" + name,
+				parent: e,
+			})
+		}
+	})
+	return res
+}
+
+func (e *element) Attribute(name string) (interface{}, bool) {
+	names, values := e.attributes()
+	for k, v := range names {
+		if v == name {
+			return values[k], true
+		}
+	}
+	return nil, false
+}
+
+func (e *element) Attributes() []string {
+	names, _ := e.attributes()
+	return names
+}
+
+func (e *element) attributes() ([]string, []interface{}) {
+	var (
+		names  = make([]string, 0)
+		values []interface{}
+	)
+	if e.node == nil {
+		return names, values
+	}
+
+	add := func(name string, value interface{}) {
+		names = append(names, name)
+		values = append(values, value)
+	}
+	fields(e.node, func(name string, value reflect.Value) {
+		if value.Kind() == reflect.Interface && !value.IsNil() {
+			value = value.Elem()
+		}
+		if x, ok := value.Interface().(*ast.Ident); ok {
+			if x != nil {
+				add(name, x.Name)
+			}
+			return
+		}
+
+		switch value.Type() {
+		case posType:
+			return
+		case tokenType:
+			add(name, value.Interface().(token.Token).String())
+			return
+		}
+		switch value.Kind() {
+		case reflect.String:
+			add(name, value.String())
+		case reflect.Bool:
+			add(name, value.Bool())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			add(name, value.Int())
+		}
+	})
+
+	if e.fset != nil && e.node.Pos().IsValid() {
+		var (
+			pos = e.fset.Position(e.node.Pos())
+			end = e.fset.Position(e.node.End())
+		)
+		add("Filename", pos.Filename)
+		add("Line", pos.Line)
+		add("Column", pos.Column)
+		add("EndLine", end.Line)
+		add("EndColumn", end.Column)
+	}
+	return names, values
+}
+
+// fields calls fn with every exported field of the node, leaving out the
+// fields of a file that repeat nodes found elsewhere in it.
+func fields(node ast.Node, fn func(string, reflect.Value)) {
+	value := reflect.Indirect(reflect.ValueOf(node))
+	if value.Kind() != reflect.Struct {
+		return
+	}
+
+	_, file := node.(*ast.File)
+	for i := 0; i < value.NumField(); i++ {
+		f := value.Type().Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		if file && (f.Name == "Imports" || f.Name == "Unresolved") {
+			continue
+		}
+		fn(f.Name, value.Field(i))
+	}
+}
+
+// children returns the nodes held by a field, which is either a node or a
+// slice of nodes.
+func children(value reflect.Value) []ast.Node {
+	var res []ast.Node
+	switch {
+	case value.Type().Implements(nodeType):
+		if !value.IsNil() {
+			res = append(res, value.Interface().(ast.Node))
+		}
+	case value.Kind() == reflect.Slice && value.Type().Elem().Implements(nodeType):
+		for i := 0; i < value.Len(); i++ {
+			if item := value.Index(i); !item.IsNil() {
+				res = append(res, item.Interface().(ast.Node))
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,82 @@
+package astelements_test
+
+import (
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+
+	"github.com/SimonRichardson/cilli"
+	"github.com/SimonRichardson/cilli/astelements"
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+const source = `package main
+
+import "fmt"
+
+func Execute(name string) error {
+	fmt.Println("executing", name)
+	return run(name)
+}
+
+func run(name string) error {
+	return fmt.Errorf("%s", name)
+}
+`
+
+func execute(t *testing.T, dsl string, element s.Element) []interface{} {
+	lex := cilli.NewPathLexer(dsl).With(s.PathTokenTypes())
+	expr, err := cilli.NewPathParser(lex.Iter()).ParseExpression()
+	if err != nil {
+		t.Fatalf("%s: %v", dsl, err)
+	}
+	res, err := cilli.NewPath(expr).ExecuteValues(element)
+	if err != nil {
+		t.Fatalf("%s: %v", dsl, err)
+	}
+	return res.Values()
+}
+
+func Test_ASTElements(t *testing.T) {
+	var (
+		fset      = token.NewFileSet()
+		file, err = parser.ParseFile(fset, "main.go", source, parser.ParseComments)
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := astelements.MakeElement(fset, file)
+
+	for _, test := range []struct {
+		dsl    string
+		values []interface{}
+	}{
+		{`//FuncDecl.(@Name=="Execute")/Body//CallExpr/@Line`, []interface{}{6, 7}},
+		{`//FuncDecl.(@Name=="Execute")/Body//CallExpr/Fun/SelectorExpr/@Sel`, []interface{}{"Println"}},
+		{`//CallExpr.(@Fun=="run")/Args/Ident/@Name`, []interface{}{"name"}},
+		{`//BasicLit.(@Kind=="STRING")/@Value`, []interface{}{`"fmt"`, `"executing"`, `"%s"`}},
+		{`//FuncDecl/@Name`, []interface{}{"Execute", "run"}},
+		{`/Decls/GenDecl/@Tok`, []interface{}{"import"}},
+		{`//ReturnStmt/Results/CallExpr/Fun/SelectorExpr/@Sel`, []interface{}{"Errorf"}},
+	} {
+		if res := execute(t, test.dsl, root); !reflect.DeepEqual(res, test.values) {
+			t.Errorf("%s: expected %v, got %v", test.dsl, test.values, res)
+		}
+	}
+}
+
+func Test_ASTElementsWithoutFileSet(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "main.go", source, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := astelements.MakeElement(nil, file)
+
+	if res := execute(t, `//FuncDecl/@Line`, root); len(res) != 0 {
+		t.Errorf("expected no lines, got %v", res)
+	}
+	if res, expected := execute(t, `//FuncDecl/@*`, root), []interface{}{"Execute", "run"}; !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected %v, got %v", expected, res)
+	}
+}
@@ -0,0 +1,86 @@
+package cilli
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+// ParseError describes where the source of a path failed to parse. Err is the
+// underlying error, such as ErrUnexpectedToken, and Expected holds the tokens
+// that would have been accepted instead, if they're known.
+type ParseError struct {
+	Err        error
+	Source     string
+	Start, End s.PathPosition
+	Expected   []s.PathTokenType
+}
+
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("%s at %s", e.Err, e.Start)
+	if len(e.Expected) > 0 {
+		expected := make([]string, len(e.Expected))
+		for k, v := range e.Expected {
+			expected[k] = fmt.Sprintf("%q", v.String())
+		}
+		msg = fmt.Sprintf("%s, expected %s", msg, strings.Join(expected, ", "))
+	}
+	return msg
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Render returns the line of the source the error starts on, underlining the
+// span of the error with carets. Spans running over multiple lines are only
+// underlined up to the end of the first line. Positions past the end of the
+// source are rendered at the end.
+func (e *ParseError) Render() string {
+	start := e.Start.Offset
+	if start > len(e.Source) {
+		start = len(e.Source)
+	} else if start < 0 {
+		start = 0
+	}
+
+	var (
+		begin = strings.LastIndexByte(e.Source[:start], '\n') + 1
+		end   = len(e.Source)
+	)
+	if index := strings.IndexByte(e.Source[begin:], '\n'); index >= 0 {
+		end = begin + index
+	}
+
+	var (
+		line   = e.Source[begin:end]
+		buffer = bytes.NewBufferString(line)
+	)
+	buffer.WriteRune('\n')
+
+	// Keep any tabs so that the carets line up with the source.
+	for _, v := range e.Source[begin:start] {
+		if v == '\t' {
+			buffer.WriteRune(v)
+		} else {
+			buffer.WriteRune(' ')
+		}
+	}
+
+	width := 1
+	if e.End.Offset > start {
+		stop := e.End.Offset
+		if stop > end {
+			stop = end
+		}
+		if n := utf8.RuneCountInString(e.Source[start:stop]); n > 0 {
+			width = n
+		}
+	}
+	buffer.WriteString(strings.Repeat("^", width))
+
+	return buffer.String()
+}
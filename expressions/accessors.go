@@ -101,6 +101,49 @@ func (p branchType) Right() s.PathExpression {
 	return p.right
 }
 
+type unionType struct {
+	left, right s.PathExpression
+}
+
+func MakePathUnion(left, right s.PathExpression) s.PathExpression {
+	return unionType{
+		left:  left,
+		right: right,
+	}
+}
+
+func (p unionType) Type() s.PathExpressionType {
+	return s.PETUnion
+}
+
+func (p unionType) Describe(w *bufio.Writer) error {
+	if x, ok := p.left.(s.Describe); ok {
+		if err := x.Describe(w); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.WriteString("|"); err != nil {
+		return err
+	}
+
+	if x, ok := p.right.(s.Describe); ok {
+		if err := x.Describe(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p unionType) Left() s.PathExpression {
+	return p.left
+}
+
+func (p unionType) Right() s.PathExpression {
+	return p.right
+}
+
 type instanceType struct {
 	left, right s.PathExpression
 }
@@ -238,6 +281,87 @@ func (p indexAccessDescendantsType) Right() s.PathExpression {
 	return p.right
 }
 
+type sliceType struct {
+	start, end, step s.PathExpression
+}
+
+// MakePathSlice creates a start:end:step slice, any of which can be nil when
+// they've been omitted.
+func MakePathSlice(start, end, step s.PathExpression) s.PathExpression {
+	return sliceType{
+		start: start,
+		end:   end,
+		step:  step,
+	}
+}
+
+func (p sliceType) Type() s.PathExpressionType {
+	return s.PETSlice
+}
+
+func (p sliceType) Describe(w *bufio.Writer) error {
+	for k, v := range []s.PathExpression{p.start, p.end, p.step} {
+		if k > 0 {
+			if _, err := w.WriteRune(':'); err != nil {
+				return err
+			}
+		}
+
+		if x, ok := v.(s.Describe); ok {
+			if err := x.Describe(w); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p sliceType) Start() s.PathExpression {
+	return p.start
+}
+
+func (p sliceType) End() s.PathExpression {
+	return p.end
+}
+
+func (p sliceType) Step() s.PathExpression {
+	return p.step
+}
+
+type indexListType struct {
+	indexes []s.PathExpression
+}
+
+func MakePathIndexList(indexes []s.PathExpression) s.PathExpression {
+	return indexListType{indexes}
+}
+
+func (p indexListType) Type() s.PathExpressionType {
+	return s.PETIndexList
+}
+
+func (p indexListType) List() []s.PathExpression {
+	return p.indexes
+}
+
+func (p indexListType) Describe(w *bufio.Writer) error {
+	for k, v := range p.indexes {
+		if x, ok := v.(s.Describe); ok {
+			if err := x.Describe(w); err != nil {
+				return err
+			}
+		}
+		if k < len(p.indexes)-1 {
+			if _, err := w.WriteString(", "); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 type descendantsType struct {
 	expType     s.PathDescendantsType
 	descendants s.PathExpression
@@ -294,3 +418,48 @@ func (p descendantsType) ExpType() s.PathDescendantsType {
 func (p descendantsType) Descendants() s.PathExpression {
 	return p.descendants
 }
+
+type axisType struct {
+	axis s.PathAxisType
+	test s.PathExpression
+}
+
+// MakePathAxis creates a step along the axis, selecting the nodes that match
+// the test.
+func MakePathAxis(axis s.PathAxisType, test s.PathExpression) s.PathExpression {
+	return axisType{
+		axis: axis,
+		test: test,
+	}
+}
+
+func (p axisType) Type() s.PathExpressionType {
+	return s.PETAxis
+}
+
+func (p axisType) Describe(w *bufio.Writer) error {
+	if p.axis == s.PAXParent && p.test.Type() == s.PETWildcard {
+		_, err := w.WriteString("..")
+		return err
+	}
+
+	if _, err := w.WriteString(p.axis.String() + "::"); err != nil {
+		return err
+	}
+
+	if x, ok := p.test.(s.Describe); ok {
+		if err := x.Describe(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p axisType) Axis() s.PathAxisType {
+	return p.axis
+}
+
+func (p axisType) Test() s.PathExpression {
+	return p.test
+}
@@ -0,0 +1,140 @@
+package expressions
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+	"math"
+	"reflect"
+
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+// Equal reports if both expressions are the same query, made up of the same
+// expressions holding the same values. Errors are equal when their messages
+// are. Custom expressions are compared with reflect.DeepEqual.
+func Equal(a, b s.PathExpression) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || a.Type() != b.Type() {
+		return false
+	}
+
+	switch x := a.(type) {
+	case emptyType, booleanType, integerType, nameType, numberType, stringType:
+		return a == b
+	case errorType:
+		y := b.(errorType)
+		return x.err.Error() == y.err.Error() && Equal(x.operand, y.operand)
+	case sliceType:
+		y := b.(sliceType)
+		return Equal(x.start, y.start) && Equal(x.end, y.end) && Equal(x.step, y.step)
+	case axisType:
+		y := b.(axisType)
+		return x.axis == y.axis && Equal(x.test, y.test)
+	case PathPrefixExpression:
+		y := b.(PathPrefixExpression)
+		return x.operator == y.operator && Equal(x.right, y.right)
+	case PathPostfixExpression:
+		y := b.(PathPostfixExpression)
+		return x.operator == y.operator && Equal(x.left, y.left)
+	case nameDescendantsType, branchType, unionType, instanceType,
+		indexAccessType, indexAccessDescendantsType, infixAttributeType,
+		equalityType, inequalityType, greaterThanType, greaterThanOrEqualToType,
+		lessThanType, lessThanOrEqualToType, logicalAndType, logicalOrType,
+		logicalNotType, descendantsType, attributeType, indexListType,
+		groupType, methodCallType:
+		left, right := Children(a), Children(b)
+		if len(left) != len(right) {
+			return false
+		}
+		for k, v := range left {
+			if !Equal(v, right[k]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Hash returns a hash of the expression that's the same for equal expressions
+// and stable between runs, so it can be used to key caches of queries. Only
+// the type and children of custom expressions are hashed.
+func Hash(expr s.PathExpression) uint64 {
+	h := &hasher{fnv.New64a()}
+	h.expression(expr)
+	return h.Sum64()
+}
+
+type hasher struct {
+	hash.Hash64
+}
+
+func (h *hasher) expression(expr s.PathExpression) {
+	if expr == nil {
+		h.uint(0)
+		return
+	}
+	h.uint(uint64(expr.Type()) + 1)
+
+	switch x := expr.(type) {
+	case booleanType:
+		if x.value {
+			h.uint(1)
+		} else {
+			h.uint(0)
+		}
+		return
+	case integerType:
+		h.uint(uint64(x.value))
+		return
+	case numberType:
+		// Both zeros are equal, so they have to hash the same.
+		if x.value == 0 {
+			h.uint(0)
+		} else {
+			h.uint(math.Float64bits(x.value))
+		}
+		return
+	case nameType:
+		h.string(x.value)
+		return
+	case stringType:
+		h.string(x.value)
+		return
+	case errorType:
+		h.string(x.err.Error())
+		h.expression(x.operand)
+		return
+	case sliceType:
+		h.expression(x.start)
+		h.expression(x.end)
+		h.expression(x.step)
+		return
+	case axisType:
+		h.uint(uint64(x.axis))
+	case PathPrefixExpression:
+		h.uint(uint64(x.operator))
+	case PathPostfixExpression:
+		h.uint(uint64(x.operator))
+	}
+
+	children := Children(expr)
+	h.uint(uint64(len(children)))
+	for _, v := range children {
+		h.expression(v)
+	}
+}
+
+func (h *hasher) uint(v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	h.Write(b[:])
+}
+
+func (h *hasher) string(v string) {
+	h.uint(uint64(len(v)))
+	h.Write([]byte(v))
+}
@@ -51,6 +51,14 @@ func (p PathPrefixExpression) Type() s.PathExpressionType {
 	return p.right.Type()
 }
 
+func (p PathPrefixExpression) Operator() s.PathTokenType {
+	return p.operator
+}
+
+func (p PathPrefixExpression) Right() s.PathExpression {
+	return p.right
+}
+
 type PathPostfixExpression struct {
 	operator s.PathTokenType
 	left     s.PathExpression
@@ -85,3 +93,11 @@ func (p PathPostfixExpression) Describe(w *bufio.Writer) error {
 func (p PathPostfixExpression) Type() s.PathExpressionType {
 	return p.left.Type()
 }
+
+func (p PathPostfixExpression) Operator() s.PathTokenType {
+	return p.operator
+}
+
+func (p PathPostfixExpression) Left() s.PathExpression {
+	return p.left
+}
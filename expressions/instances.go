@@ -7,19 +7,51 @@ import (
 )
 
 type attributeType struct {
+	name s.PathExpression
 }
 
+// MakePathAttribute returns an attribute without a name. Use
+// MakePathNamedAttribute for an attribute that can be looked up.
 func MakePathAttribute() s.PathExpression {
 	return attributeType{}
 }
 
+func MakePathNamedAttribute(name s.PathExpression) s.PathExpression {
+	return attributeType{name}
+}
+
 func (p attributeType) Type() s.PathExpressionType {
 	return s.PETAttribute
 }
 
+func (p attributeType) Operand() s.PathExpression {
+	return p.name
+}
+
+func (p attributeType) Name() string {
+	if x, ok := p.name.(s.Name); ok {
+		return x.Name()
+	}
+	return ""
+}
+
 func (p attributeType) Describe(w *bufio.Writer) error {
-	_, err := w.WriteString(p.Type().String())
-	return err
+	if p.name == nil {
+		_, err := w.WriteString(p.Type().String())
+		return err
+	}
+
+	if _, err := w.WriteString("@"); err != nil {
+		return err
+	}
+
+	if x, ok := p.name.(s.Describe); ok {
+		if err := x.Describe(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 type methodCallType struct {
@@ -52,6 +84,10 @@ func (p methodCallType) Describe(w *bufio.Writer) error {
 		}
 	}
 
+	if _, err := w.WriteRune('('); err != nil {
+		return err
+	}
+
 	for k, v := range p.parameters {
 		if x, ok := v.(s.Describe); ok {
 			if err := x.Describe(w); err != nil {
@@ -63,6 +99,10 @@ func (p methodCallType) Describe(w *bufio.Writer) error {
 		}
 	}
 
+	if _, err := w.WriteRune(')'); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -0,0 +1,365 @@
+package expressions
+
+import (
+	"encoding/json"
+	"errors"
+
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+var (
+	ErrUnknownExpressionType = errors.New("Unknown Expression Type")
+	ErrInvalidExpressionJSON = errors.New("Invalid Expression JSON")
+)
+
+// jsonExpression is the encoding of every expression, keyed by the String of
+// its type. Operators use left and right, unary expressions, attributes and
+// descendants use operand, and groups, index lists and the parameters of a
+// method call use list. Literals and names hold their value.
+type jsonExpression struct {
+	Type    string            `json:"type"`
+	Value   json.RawMessage   `json:"value,omitempty"`
+	Axis    string            `json:"axis,omitempty"`
+	Error   string            `json:"error,omitempty"`
+	Left    *jsonExpression   `json:"left,omitempty"`
+	Right   *jsonExpression   `json:"right,omitempty"`
+	Operand *jsonExpression   `json:"operand,omitempty"`
+	Method  *jsonExpression   `json:"method,omitempty"`
+	Test    *jsonExpression   `json:"test,omitempty"`
+	Start   *jsonExpression   `json:"start,omitempty"`
+	End     *jsonExpression   `json:"end,omitempty"`
+	Step    *jsonExpression   `json:"step,omitempty"`
+	List    []*jsonExpression `json:"list,omitempty"`
+}
+
+// binaries holds the constructors of the expressions made up of a left and a
+// right expression.
+var binaries = map[s.PathExpressionType]func(s.PathExpression, s.PathExpression) s.PathExpression{
+	s.PETNameDescendants:        MakePathNameDescendants,
+	s.PETbranch:                 MakePathBranch,
+	s.PETUnion:                  MakePathUnion,
+	s.PETInstance:               MakePathInstance,
+	s.PETIndexAccess:            MakePathIndexAccess,
+	s.PETIndexAccessDescendants: MakePathIndexAccessDescendants,
+	s.PETInfixAttribute:         MakePathInfixAttribute,
+	s.PETEquality:               MakePathEquality,
+	s.PETInequality:             MakePathInequality,
+	s.PETGreaterThan:            MakePathGreaterThan,
+	s.PETGreaterThanOrEqualTo:   MakePathGreaterThanOrEqualTo,
+	s.PETLessThan:               MakePathLessThan,
+	s.PETLessThanOrEqualTo:      MakePathLessThanOrEqualTo,
+	s.PETLogicalAnd:             MakePathLogicalAnd,
+	s.PETLogicalOr:              MakePathLogicalOr,
+}
+
+// Encode returns the JSON encoding of the expression. Only the expressions of
+// this package can be encoded, the prefix and postfix expressions share the
+// type of their operand, so they can't be encoded either.
+func Encode(expr s.PathExpression) ([]byte, error) {
+	res, err := encode(expr)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(res)
+}
+
+func encode(expr s.PathExpression) (*jsonExpression, error) {
+	if expr == nil {
+		return nil, nil
+	}
+
+	var (
+		res = &jsonExpression{
+			Type: expr.Type().String(),
+		}
+		err error
+	)
+	switch x := expr.(type) {
+	case nameDescendantsType, branchType, unionType, instanceType,
+		indexAccessType, indexAccessDescendantsType, infixAttributeType,
+		equalityType, inequalityType, greaterThanType, greaterThanOrEqualToType,
+		lessThanType, lessThanOrEqualToType, logicalAndType, logicalOrType:
+		y := x.(s.Branch)
+		if res.Left, err = encode(y.Left()); err != nil {
+			return nil, err
+		}
+		if res.Right, err = encode(y.Right()); err != nil {
+			return nil, err
+		}
+	case emptyType:
+	case nameType:
+		res.Value, err = json.Marshal(x.value)
+	case stringType:
+		res.Value, err = json.Marshal(x.value)
+	case numberType:
+		res.Value, err = json.Marshal(x.value)
+	case integerType:
+		res.Value, err = json.Marshal(x.value)
+	case booleanType:
+		res.Value, err = json.Marshal(x.value)
+	case descendantsType:
+		res.Operand, err = encode(x.descendants)
+	case attributeType:
+		res.Operand, err = encode(x.name)
+	case logicalNotType:
+		res.Operand, err = encode(x.operand)
+	case errorType:
+		res.Error = x.err.Error()
+		res.Operand, err = encode(x.operand)
+	case axisType:
+		res.Axis = x.axis.String()
+		res.Test, err = encode(x.test)
+	case sliceType:
+		if res.Start, err = encode(x.start); err != nil {
+			return nil, err
+		}
+		if res.End, err = encode(x.end); err != nil {
+			return nil, err
+		}
+		res.Step, err = encode(x.step)
+	case indexListType:
+		res.List, err = encodeAll(x.indexes)
+	case groupType:
+		res.List, err = encodeAll(x.expressions)
+	case methodCallType:
+		if res.Method, err = encode(x.method); err != nil {
+			return nil, err
+		}
+		res.List, err = encodeAll(x.parameters)
+	default:
+		return nil, ErrUnknownExpressionType
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func encodeAll(exprs []s.PathExpression) ([]*jsonExpression, error) {
+	res := make([]*jsonExpression, len(exprs))
+	for k, v := range exprs {
+		var err error
+		if res[k], err = encode(v); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// Decode rebuilds the expression from its JSON encoding. Errors of any error
+// expressions only keep their message.
+func Decode(data []byte) (s.PathExpression, error) {
+	var res *jsonExpression
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return nil, ErrInvalidExpressionJSON
+	}
+	return decode(res)
+}
+
+func decode(x *jsonExpression) (s.PathExpression, error) {
+	var expressionType s.PathExpressionType
+	for _, v := range s.PathExpressionTypes() {
+		if v.String() == x.Type {
+			expressionType = v
+			break
+		}
+	}
+	if expressionType.String() != x.Type {
+		return nil, ErrUnknownExpressionType
+	}
+
+	if fn, ok := binaries[expressionType]; ok {
+		left, err := decodeRequired(x.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := decodeRequired(x.Right)
+		if err != nil {
+			return nil, err
+		}
+		return fn(left, right), nil
+	}
+
+	switch expressionType {
+	case s.PETWildcard:
+		return MakePathWildcard(), nil
+
+	case s.PETName:
+		var value string
+		if err := decodeValue(x.Value, &value); err != nil {
+			return nil, err
+		}
+		return MakePathName(value), nil
+	case s.PETString:
+		var value string
+		if err := decodeValue(x.Value, &value); err != nil {
+			return nil, err
+		}
+		return MakePathString(value), nil
+	case s.PETNumber:
+		var value float64
+		if err := decodeValue(x.Value, &value); err != nil {
+			return nil, err
+		}
+		return MakePathNumber(value), nil
+	case s.PETInteger:
+		var value int
+		if err := decodeValue(x.Value, &value); err != nil {
+			return nil, err
+		}
+		return MakePathInteger(value), nil
+	case s.PETBoolean:
+		var value bool
+		if err := decodeValue(x.Value, &value); err != nil {
+			return nil, err
+		}
+		return MakePathBoolean(value), nil
+
+	case s.PETDescendants, s.PETAllDescendants:
+		operand, err := decodeRequired(x.Operand)
+		if err != nil {
+			return nil, err
+		}
+		descendantsType := s.PDTContext
+		if expressionType == s.PETAllDescendants {
+			descendantsType = s.PDTAll
+		}
+		return MakePathDescendants(descendantsType, operand), nil
+	case s.PETAttribute:
+		operand, err := decodeOptional(x.Operand)
+		if err != nil {
+			return nil, err
+		}
+		return MakePathNamedAttribute(operand), nil
+	case s.PETLogicalNot:
+		operand, err := decodeRequired(x.Operand)
+		if err != nil {
+			return nil, err
+		}
+		return MakePathLogicalNot(operand), nil
+	case s.PETError:
+		operand, err := decodeOptional(x.Operand)
+		if err != nil {
+			return nil, err
+		}
+		return MakePathError(errors.New(x.Error), operand), nil
+
+	case s.PETAxis:
+		test, err := decodeRequired(x.Test)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range s.PathAxisTypes() {
+			if v.String() == x.Axis {
+				return MakePathAxis(v, test), nil
+			}
+		}
+		return nil, ErrInvalidExpressionJSON
+
+	case s.PETSlice:
+		start, err := decodeOptional(x.Start)
+		if err != nil {
+			return nil, err
+		}
+		end, err := decodeOptional(x.End)
+		if err != nil {
+			return nil, err
+		}
+		step, err := decodeOptional(x.Step)
+		if err != nil {
+			return nil, err
+		}
+		return MakePathSlice(start, end, step), nil
+
+	case s.PETIndexList:
+		list, err := decodeAll(x.List)
+		if err != nil {
+			return nil, err
+		}
+		return MakePathIndexList(list), nil
+	case s.PETGroup:
+		list, err := decodeAll(x.List)
+		if err != nil {
+			return nil, err
+		}
+		return MakePathGroup(list), nil
+	case s.PETMethodCall:
+		method, err := decodeRequired(x.Method)
+		if err != nil {
+			return nil, err
+		}
+		list, err := decodeAll(x.List)
+		if err != nil {
+			return nil, err
+		}
+		return MakePathMethodCall(method, list), nil
+	}
+	return nil, ErrUnknownExpressionType
+}
+
+func decodeRequired(x *jsonExpression) (s.PathExpression, error) {
+	if x == nil {
+		return nil, ErrInvalidExpressionJSON
+	}
+	return decode(x)
+}
+
+func decodeOptional(x *jsonExpression) (s.PathExpression, error) {
+	if x == nil {
+		return nil, nil
+	}
+	return decode(x)
+}
+
+// decodeAll always returns a list, even if it's empty, to match the parser.
+func decodeAll(x []*jsonExpression) ([]s.PathExpression, error) {
+	res := make([]s.PathExpression, len(x))
+	for k, v := range x {
+		var err error
+		if res[k], err = decodeRequired(v); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func decodeValue(data json.RawMessage, value interface{}) error {
+	if len(data) == 0 {
+		return ErrInvalidExpressionJSON
+	}
+	return json.Unmarshal(data, value)
+}
+
+func (p nameDescendantsType) MarshalJSON() ([]byte, error)        { return Encode(p) }
+func (p branchType) MarshalJSON() ([]byte, error)                 { return Encode(p) }
+func (p unionType) MarshalJSON() ([]byte, error)                  { return Encode(p) }
+func (p instanceType) MarshalJSON() ([]byte, error)               { return Encode(p) }
+func (p indexAccessType) MarshalJSON() ([]byte, error)            { return Encode(p) }
+func (p indexAccessDescendantsType) MarshalJSON() ([]byte, error) { return Encode(p) }
+func (p infixAttributeType) MarshalJSON() ([]byte, error)         { return Encode(p) }
+func (p equalityType) MarshalJSON() ([]byte, error)               { return Encode(p) }
+func (p inequalityType) MarshalJSON() ([]byte, error)             { return Encode(p) }
+func (p greaterThanType) MarshalJSON() ([]byte, error)            { return Encode(p) }
+func (p greaterThanOrEqualToType) MarshalJSON() ([]byte, error)   { return Encode(p) }
+func (p lessThanType) MarshalJSON() ([]byte, error)               { return Encode(p) }
+func (p lessThanOrEqualToType) MarshalJSON() ([]byte, error)      { return Encode(p) }
+func (p logicalAndType) MarshalJSON() ([]byte, error)             { return Encode(p) }
+func (p logicalOrType) MarshalJSON() ([]byte, error)              { return Encode(p) }
+func (p logicalNotType) MarshalJSON() ([]byte, error)             { return Encode(p) }
+func (p emptyType) MarshalJSON() ([]byte, error)                  { return Encode(p) }
+func (p nameType) MarshalJSON() ([]byte, error)                   { return Encode(p) }
+func (p stringType) MarshalJSON() ([]byte, error)                 { return Encode(p) }
+func (p numberType) MarshalJSON() ([]byte, error)                 { return Encode(p) }
+func (p integerType) MarshalJSON() ([]byte, error)                { return Encode(p) }
+func (p booleanType) MarshalJSON() ([]byte, error)                { return Encode(p) }
+func (p descendantsType) MarshalJSON() ([]byte, error)            { return Encode(p) }
+func (p attributeType) MarshalJSON() ([]byte, error)              { return Encode(p) }
+func (p errorType) MarshalJSON() ([]byte, error)                  { return Encode(p) }
+func (p axisType) MarshalJSON() ([]byte, error)                   { return Encode(p) }
+func (p sliceType) MarshalJSON() ([]byte, error)                  { return Encode(p) }
+func (p indexListType) MarshalJSON() ([]byte, error)              { return Encode(p) }
+func (p groupType) MarshalJSON() ([]byte, error)                  { return Encode(p) }
+func (p methodCallType) MarshalJSON() ([]byte, error)             { return Encode(p) }
@@ -200,7 +200,7 @@ func (p lessThanType) Describe(w *bufio.Writer) error {
 		}
 	}
 
-	if _, err := w.WriteString(">"); err != nil {
+	if _, err := w.WriteString("<"); err != nil {
 		return err
 	}
 
@@ -243,7 +243,7 @@ func (p lessThanOrEqualToType) Describe(w *bufio.Writer) error {
 		}
 	}
 
-	if _, err := w.WriteString(">="); err != nil {
+	if _, err := w.WriteString("<="); err != nil {
 		return err
 	}
 
@@ -349,3 +349,35 @@ func (p logicalOrType) Left() s.PathExpression {
 func (p logicalOrType) Right() s.PathExpression {
 	return p.right
 }
+
+type logicalNotType struct {
+	operand s.PathExpression
+}
+
+func MakePathLogicalNot(operand s.PathExpression) s.PathExpression {
+	return logicalNotType{
+		operand: operand,
+	}
+}
+
+func (p logicalNotType) Type() s.PathExpressionType {
+	return s.PETLogicalNot
+}
+
+func (p logicalNotType) Describe(w *bufio.Writer) error {
+	if _, err := w.WriteString("!"); err != nil {
+		return err
+	}
+
+	if x, ok := p.operand.(s.Describe); ok {
+		if err := x.Describe(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p logicalNotType) Operand() s.PathExpression {
+	return p.operand
+}
@@ -3,6 +3,7 @@ package expressions
 import (
 	"bufio"
 	"fmt"
+	"unicode"
 
 	s "github.com/SimonRichardson/cilli/selectors"
 )
@@ -98,11 +99,26 @@ func (p nameType) Value() interface{} {
 	return p.value
 }
 
+// Describe quotes the name when it can't be written as is, so that it's read
+// back as a quoted name.
 func (p nameType) Describe(w *bufio.Writer) error {
-	_, err := w.WriteString(fmt.Sprintf("%s", p.value))
+	format := "%s"
+	if !plainName(p.value) {
+		format = "%q"
+	}
+	_, err := w.WriteString(fmt.Sprintf(format, p.value))
 	return err
 }
 
+func plainName(name string) bool {
+	for k, v := range name {
+		if !(unicode.IsLetter(v) || v == '_' || (k > 0 && (unicode.IsDigit(v) || v == '-'))) {
+			return false
+		}
+	}
+	return name != ""
+}
+
 func (p nameType) Name() string {
 	return p.value
 }
@@ -160,3 +176,40 @@ func (p stringType) Describe(w *bufio.Writer) error {
 	_, err := w.WriteString(fmt.Sprintf("%q", p.value))
 	return err
 }
+
+type errorType struct {
+	err     error
+	operand s.PathExpression
+}
+
+// MakePathError creates a node for an expression that failed to parse, the
+// operand holds whatever was parsed before the error and can be nil.
+func MakePathError(err error, operand s.PathExpression) s.PathExpression {
+	return errorType{
+		err:     err,
+		operand: operand,
+	}
+}
+
+func (p errorType) Type() s.PathExpressionType {
+	return s.PETError
+}
+
+func (p errorType) Err() error {
+	return p.err
+}
+
+func (p errorType) Operand() s.PathExpression {
+	return p.operand
+}
+
+func (p errorType) Describe(w *bufio.Writer) error {
+	if x, ok := p.operand.(s.Describe); ok {
+		if err := x.Describe(w); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.WriteString("<error>")
+	return err
+}
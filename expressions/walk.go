@@ -0,0 +1,156 @@
+package expressions
+
+import (
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+// Visitor is called by Walk for every expression. If the visitor returned is
+// not nil, it's used to visit the children of the expression, followed by a
+// call to Visit(nil).
+type Visitor interface {
+	Visit(s.PathExpression) Visitor
+}
+
+// Walk traverses the expression depth first, in the order the expressions
+// were written.
+func Walk(expr s.PathExpression, v Visitor) {
+	if v = v.Visit(expr); v == nil {
+		return
+	}
+	for _, child := range Children(expr) {
+		Walk(child, v)
+	}
+	v.Visit(nil)
+}
+
+type inspector func(s.PathExpression) bool
+
+func (f inspector) Visit(expr s.PathExpression) Visitor {
+	if f(expr) {
+		return f
+	}
+	return nil
+}
+
+// Inspect walks the expression, calling fn for every expression and then with
+// nil once the children of the expression have been visited. Returning false
+// skips the children.
+func Inspect(expr s.PathExpression, fn func(s.PathExpression) bool) {
+	Walk(expr, inspector(fn))
+}
+
+// Children returns the expressions directly below the expression, omitted
+// parts such as the end of a slice are left out. Custom expressions only have
+// children if they implement the interfaces of selectors, such as s.Branch.
+func Children(expr s.PathExpression) []s.PathExpression {
+	var res []s.PathExpression
+	switch x := expr.(type) {
+	case s.Branch:
+		res = []s.PathExpression{x.Left(), x.Right()}
+	case s.Descendants:
+		res = []s.PathExpression{x.Descendants()}
+	case s.Axis:
+		res = []s.PathExpression{x.Test()}
+	case s.List:
+		res = x.List()
+	case s.MethodCall:
+		res = append([]s.PathExpression{x.Method()}, x.Parameters()...)
+	case s.Unary:
+		res = []s.PathExpression{x.Operand()}
+	case sliceType:
+		res = []s.PathExpression{x.start, x.end, x.step}
+	case PathPrefixExpression:
+		res = []s.PathExpression{x.right}
+	case PathPostfixExpression:
+		res = []s.PathExpression{x.left}
+	}
+
+	children := make([]s.PathExpression, 0, len(res))
+	for _, v := range res {
+		if v != nil {
+			children = append(children, v)
+		}
+	}
+	return children
+}
+
+// Rewrite rebuilds the expression from the bottom up, replacing every
+// expression with the one returned by fn. The children of an expression are
+// rewritten before fn is called with it.
+func Rewrite(expr s.PathExpression, fn func(s.PathExpression) s.PathExpression) s.PathExpression {
+	if expr == nil {
+		return nil
+	}
+
+	var (
+		rewrite = func(x s.PathExpression) s.PathExpression {
+			return Rewrite(x, fn)
+		}
+		rewriteAll = func(x []s.PathExpression) []s.PathExpression {
+			res := make([]s.PathExpression, len(x))
+			for k, v := range x {
+				res[k] = rewrite(v)
+			}
+			return res
+		}
+	)
+
+	var res s.PathExpression
+	switch x := expr.(type) {
+	case nameDescendantsType:
+		res = MakePathNameDescendants(rewrite(x.left), rewrite(x.right))
+	case branchType:
+		res = MakePathBranch(rewrite(x.left), rewrite(x.right))
+	case unionType:
+		res = MakePathUnion(rewrite(x.left), rewrite(x.right))
+	case instanceType:
+		res = MakePathInstance(rewrite(x.left), rewrite(x.right))
+	case indexAccessType:
+		res = MakePathIndexAccess(rewrite(x.left), rewrite(x.right))
+	case indexAccessDescendantsType:
+		res = MakePathIndexAccessDescendants(rewrite(x.left), rewrite(x.right))
+	case infixAttributeType:
+		res = MakePathInfixAttribute(rewrite(x.left), rewrite(x.right))
+	case equalityType:
+		res = MakePathEquality(rewrite(x.left), rewrite(x.right))
+	case inequalityType:
+		res = MakePathInequality(rewrite(x.left), rewrite(x.right))
+	case greaterThanType:
+		res = MakePathGreaterThan(rewrite(x.left), rewrite(x.right))
+	case greaterThanOrEqualToType:
+		res = MakePathGreaterThanOrEqualTo(rewrite(x.left), rewrite(x.right))
+	case lessThanType:
+		res = MakePathLessThan(rewrite(x.left), rewrite(x.right))
+	case lessThanOrEqualToType:
+		res = MakePathLessThanOrEqualTo(rewrite(x.left), rewrite(x.right))
+	case logicalAndType:
+		res = MakePathLogicalAnd(rewrite(x.left), rewrite(x.right))
+	case logicalOrType:
+		res = MakePathLogicalOr(rewrite(x.left), rewrite(x.right))
+	case logicalNotType:
+		res = MakePathLogicalNot(rewrite(x.operand))
+	case sliceType:
+		res = MakePathSlice(rewrite(x.start), rewrite(x.end), rewrite(x.step))
+	case indexListType:
+		res = MakePathIndexList(rewriteAll(x.indexes))
+	case descendantsType:
+		res = MakePathDescendants(x.expType, rewrite(x.descendants))
+	case axisType:
+		res = MakePathAxis(x.axis, rewrite(x.test))
+	case attributeType:
+		res = MakePathNamedAttribute(rewrite(x.name))
+	case methodCallType:
+		res = MakePathMethodCall(rewrite(x.method), rewriteAll(x.parameters))
+	case groupType:
+		res = MakePathGroup(rewriteAll(x.expressions))
+	case errorType:
+		res = MakePathError(x.err, rewrite(x.operand))
+	case PathPrefixExpression:
+		res = MakePathPrefix(x.operator, rewrite(x.right))
+	case PathPostfixExpression:
+		res = MakePathPostfix(x.operator, rewrite(x.left))
+	default:
+		res = expr
+	}
+	return fn(res)
+}
@@ -0,0 +1,329 @@
+package cilli
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+
+	"github.com/SimonRichardson/cilli/expressions"
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+var (
+	ErrUnformattableExpression = errors.New("Unformattable Expression")
+)
+
+// PathFormatter prints expressions back into the DSL. With Spacing the
+// comparison, logical and union operators are surrounded by spaces and commas
+// are followed by one.
+type PathFormatter struct {
+	Spacing bool
+}
+
+// Format prints the expression as canonical DSL, without any spacing.
+func Format(expression s.PathExpression) (string, error) {
+	return PathFormatter{}.Format(expression)
+}
+
+// Format prints the expression as canonical DSL. Parsing the result gives an
+// expression equal to the one formatted, as long as that was parsed from the
+// DSL using the default lexer and parser. Expressions that failed to parse and
+// custom expressions can't be formatted.
+func (f PathFormatter) Format(expression s.PathExpression) (string, error) {
+	printer := &pathPrinter{spacing: f.Spacing}
+	if err := printer.print(expression, false); err != nil {
+		return "", err
+	}
+	return printer.buffer.String(), nil
+}
+
+type pathPrinter struct {
+	spacing bool
+	buffer  bytes.Buffer
+	last    string
+}
+
+// print writes the expression, step reports if the expression is the step of
+// a path, where names that aren't plain can be written quoted.
+func (p *pathPrinter) print(expression s.PathExpression, step bool) error {
+	switch x := expression.(type) {
+	case expressions.PathPrefixExpression:
+		p.write(x.Operator().String())
+		return p.print(x.Right(), false)
+	case expressions.PathPostfixExpression:
+		if err := p.print(x.Left(), false); err != nil {
+			return err
+		}
+		p.write(x.Operator().String())
+		return nil
+	}
+
+	switch expression.Type() {
+	case s.PETWildcard:
+		p.write("*")
+		return nil
+
+	case s.PETName:
+		if x, ok := expression.(s.Name); ok {
+			return p.name(x.Name(), step)
+		}
+
+	case s.PETString, s.PETNumber, s.PETInteger, s.PETBoolean:
+		if x, ok := expression.(s.Value); ok {
+			return p.value(x.Value())
+		}
+
+	case s.PETDescendants, s.PETAllDescendants:
+		if x, ok := expression.(s.Descendants); ok {
+			p.write("/")
+			if expression.Type() == s.PETAllDescendants {
+				p.write("/")
+			}
+			return p.print(x.Descendants(), true)
+		}
+
+	case s.PETNameDescendants, s.PETbranch:
+		return p.binary(expression, "/", step, true, false)
+	case s.PETInstance:
+		return p.binary(expression, ".", step, false, false)
+	case s.PETInfixAttribute:
+		return p.binary(expression, "@", step, false, false)
+	case s.PETUnion:
+		return p.binary(expression, "|", false, false, p.spacing)
+	case s.PETEquality:
+		return p.binary(expression, "==", false, false, p.spacing)
+	case s.PETInequality:
+		return p.binary(expression, "!=", false, false, p.spacing)
+	case s.PETLessThan:
+		return p.binary(expression, "<", false, false, p.spacing)
+	case s.PETLessThanOrEqualTo:
+		return p.binary(expression, "<=", false, false, p.spacing)
+	case s.PETGreaterThan:
+		return p.binary(expression, ">", false, false, p.spacing)
+	case s.PETGreaterThanOrEqualTo:
+		return p.binary(expression, ">=", false, false, p.spacing)
+	case s.PETLogicalAnd:
+		return p.binary(expression, "&&", false, false, p.spacing)
+	case s.PETLogicalOr:
+		return p.binary(expression, "||", false, false, p.spacing)
+
+	case s.PETIndexAccess, s.PETIndexAccessDescendants:
+		if x, ok := expression.(s.Branch); ok {
+			if err := p.print(x.Left(), step); err != nil {
+				return err
+			}
+			p.write("[")
+			if err := p.print(x.Right(), false); err != nil {
+				return err
+			}
+			p.write("]")
+			return nil
+		}
+
+	case s.PETSlice:
+		if x, ok := expression.(interface {
+			Start() s.PathExpression
+			End() s.PathExpression
+			Step() s.PathExpression
+		}); ok {
+			return p.slice(x.Start(), x.End(), x.Step())
+		}
+
+	case s.PETIndexList:
+		if x, ok := expression.(s.List); ok {
+			return p.list(x.List())
+		}
+
+	case s.PETAttribute:
+		if x, ok := expression.(s.Unary); ok {
+			p.write("@")
+			// Keywords are read as names, but the name can't be quoted.
+			if y, ok := x.Operand().(s.Name); ok && x.Operand().Type() == s.PETName && keywords[y.Name()] {
+				p.write(y.Name())
+				return nil
+			}
+			return p.print(x.Operand(), false)
+		}
+
+	case s.PETMethodCall:
+		if x, ok := expression.(s.MethodCall); ok {
+			if err := p.print(x.Method(), false); err != nil {
+				return err
+			}
+			p.write("(")
+			if err := p.list(x.Parameters()); err != nil {
+				return err
+			}
+			p.write(")")
+			return nil
+		}
+
+	case s.PETGroup:
+		if x, ok := expression.(s.List); ok {
+			p.write("(")
+			for k, v := range x.List() {
+				if k > 0 {
+					p.space()
+				}
+				if err := p.print(v, false); err != nil {
+					return err
+				}
+			}
+			p.write(")")
+			return nil
+		}
+
+	case s.PETLogicalNot:
+		if x, ok := expression.(s.Unary); ok {
+			p.write("!")
+			return p.print(x.Operand(), false)
+		}
+
+	case s.PETAxis:
+		if x, ok := expression.(s.Axis); ok {
+			// The abbreviations bind the same as the axes they stand for,
+			// because the parser would have taken anything binding tighter as
+			// part of the test.
+			if x.Test().Type() == s.PETWildcard {
+				switch x.Axis() {
+				case s.PAXSelf:
+					p.write(".")
+					return nil
+				case s.PAXParent:
+					p.write("..")
+					return nil
+				}
+			}
+			p.write(x.Axis().String())
+			p.write("::")
+			return p.print(x.Test(), true)
+		}
+	}
+	return ErrUnformattableExpression
+}
+
+// binary writes both sides of the expression around the operator, only the
+// left side is a step when the expression is, whilst both sides of a path are.
+func (p *pathPrinter) binary(expression s.PathExpression, operator string, step, path, spaced bool) error {
+	x, ok := expression.(s.Branch)
+	if !ok {
+		return ErrUnformattableExpression
+	}
+
+	if err := p.print(x.Left(), step || path); err != nil {
+		return err
+	}
+	if spaced {
+		p.space()
+	}
+	p.write(operator)
+	if spaced {
+		p.space()
+	}
+	return p.print(x.Right(), path)
+}
+
+func (p *pathPrinter) slice(start, end, step s.PathExpression) error {
+	if start != nil {
+		if err := p.print(start, false); err != nil {
+			return err
+		}
+	}
+	if end == nil && step != nil {
+		p.write("::")
+		return p.print(step, false)
+	}
+
+	p.write(":")
+	if end != nil {
+		if err := p.print(end, false); err != nil {
+			return err
+		}
+	}
+	if step != nil {
+		p.write(":")
+		return p.print(step, false)
+	}
+	return nil
+}
+
+func (p *pathPrinter) list(expressions []s.PathExpression) error {
+	for k, v := range expressions {
+		if k > 0 {
+			p.write(",")
+			if p.spacing {
+				p.space()
+			}
+		}
+		if err := p.print(v, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// name writes the name as is when it's read back as the same name, or it's a
+// keyword within a step, otherwise it's quoted, which is only read back as a
+// name when it's a step.
+func (p *pathPrinter) name(name string, step bool) error {
+	lex := NewPathLexer(name).With(s.PathTokenTypes())
+	if expr, err := NewPathParser(lex.Iter()).ParseExpression(); err == nil && expr == expressions.MakePathName(name) {
+		p.write(name)
+		return nil
+	}
+	if !step {
+		return ErrUnformattableExpression
+	}
+	if keywords[name] {
+		p.write(name)
+		return nil
+	}
+	p.write(strconv.Quote(name))
+	return nil
+}
+
+// keywords holds the names that are lexed as keywords, which steps and
+// attributes read as a name.
+var keywords = func() map[string]bool {
+	res := make(map[string]bool)
+	for _, v := range s.PathTokenTypes() {
+		if v.Keyword() {
+			res[v.String()] = true
+		}
+	}
+	return res
+}()
+
+func (p *pathPrinter) value(value interface{}) error {
+	switch x := value.(type) {
+	case string:
+		p.write(strconv.Quote(x))
+	case float64:
+		p.write(strconv.FormatFloat(x, 'f', -1, 64))
+	case int:
+		p.write(strconv.Itoa(x))
+	case bool:
+		p.write(strconv.FormatBool(x))
+	default:
+		return ErrUnformattableExpression
+	}
+	return nil
+}
+
+// write writes the token, separating it from the last token with a space if
+// they'd otherwise be read back as something else, such as . and . as ..
+func (p *pathPrinter) write(token string) {
+	if p.last != "" {
+		lex := NewPathLexer(p.last + token).With(s.PathTokenTypes())
+		if res, err := lex.Iter().Next(); err != nil || res.End().Offset != len(p.last) {
+			p.space()
+		}
+	}
+	p.buffer.WriteString(token)
+	p.last = token
+}
+
+func (p *pathPrinter) space() {
+	p.buffer.WriteRune(' ')
+	p.last = ""
+}
@@ -0,0 +1,324 @@
+package cilli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/SimonRichardson/cilli/expressions"
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+func parse(t *testing.T, dsl string) s.PathExpression {
+	lex := NewPathLexer(dsl).With(s.PathTokenTypes())
+	expr, err := NewPathParser(lex.Iter()).ParseExpression()
+	if err != nil {
+		t.Fatalf("%s: %v", dsl, err)
+	}
+	return expr
+}
+
+func Test_FormatCanonical(t *testing.T) {
+	for _, test := range []struct {
+		dsl, compact, spaced string
+	}{
+		{
+			`/event[0].(@A==1 and not @B!='x')/colour|//ancestor::*`,
+			`/event[0].(@A==1&&!@B!="x")/colour|//ancestor::*`,
+			`/event[0].(@A == 1 && !@B != "x")/colour | //ancestor::*`,
+		},
+		{
+			`/node[ :: 2 ]/child[1:-1]/x[0 , 2]`,
+			`/node[::2]/child[1:-1]/x[0,2]`,
+			`/node[::2]/child[1:-1]/x[0, 2]`,
+		},
+		{
+			`/"weird name"/'not'/größe/..`,
+			`/"weird name"/not/größe/..`,
+			`/"weird name"/not/größe/..`,
+		},
+		{
+			`node.(@A<=1.50 or contains(@Title, "a\tb"))`,
+			`node.(@A<=1.5||contains(@Title,"a\tb"))`,
+			`node.(@A <= 1.5 || contains(@Title, "a\tb"))`,
+		},
+		{
+			`/not/and.(@not==1 and not @or)`,
+			`/not/and.(@not==1&&!@or)`,
+			`/not/and.(@not == 1 && !@or)`,
+		},
+		{
+			`self::*/parent::*/./../colour@Red`,
+			`./.././../colour@Red`,
+			`./.././../colour@Red`,
+		},
+	} {
+		expr := parse(t, test.dsl)
+		for formatter, expected := range map[PathFormatter]string{
+			PathFormatter{}:              test.compact,
+			PathFormatter{Spacing: true}: test.spaced,
+		} {
+			res, err := formatter.Format(expr)
+			if err != nil {
+				t.Fatalf("%s: %v", test.dsl, err)
+			}
+			if res != expected {
+				t.Errorf("%s: expected %s, got %s", test.dsl, expected, res)
+			}
+			if again := parse(t, res); !reflect.DeepEqual(again, expr) {
+				t.Errorf("%s: expected %v, got %v", test.dsl, expr, again)
+			}
+		}
+	}
+}
+
+func Test_FormatUnformattable(t *testing.T) {
+	for _, expr := range []s.PathExpression{
+		expressions.MakePathError(ErrUnexpectedToken, nil),
+		expressions.MakePathNamedAttribute(expressions.MakePathName("weird name")),
+		hashType{"col"},
+	} {
+		if _, err := Format(expr); err != ErrUnformattableExpression {
+			t.Errorf("expected %v, got %v", ErrUnformattableExpression, err)
+		}
+	}
+}
+
+// Query is a random query made up from most of the DSL.
+type Query string
+
+func (q Query) Generate(r *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(Query(generateQuery(r, size)))
+}
+
+func generateQuery(r *rand.Rand, size int) string {
+	var (
+		name = func() string {
+			return GenerateNamedWithRand(r, 1+r.Intn(8))
+		}
+		text = func() string {
+			runes := []rune("aZ09 _-./\\\"'`\t\nü世")
+			res := make([]rune, r.Intn(size+1))
+			for k := range res {
+				res[k] = runes[r.Intn(len(runes))]
+			}
+			return string(res)
+		}
+		quote = func(v string) string {
+			if r.Intn(2) == 0 {
+				return fmt.Sprintf("%q", v)
+			}
+			return fmt.Sprintf("'%s'", strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`).Replace(v))
+		}
+		pick = func(values ...string) string {
+			return values[r.Intn(len(values))]
+		}
+		number = func() string {
+			return fmt.Sprintf("%d", r.Intn(20)-10)
+		}
+		comparison = func() string {
+			switch r.Intn(4) {
+			case 0:
+				return "@" + name()
+			case 1:
+				return fmt.Sprintf("count(%s)%s%s", name(), pick("<", ">=", "=="), number())
+			}
+			return fmt.Sprintf("@%s %s %s", name(), pick("==", "!=", "<", "<=", ">", ">="), pick(quote(text()), number(), "true"))
+		}
+		predicate = func() string {
+			res := comparison()
+			for i := r.Intn(3); i > 0; i-- {
+				res = fmt.Sprintf("%s %s %s%s", res, pick("and", "&&", "or", "||"), pick("", "not ", "!"), comparison())
+			}
+			return res
+		}
+		step = func() string {
+			res := pick(name(), name(), "*", quote(text()+"x"), "ancestor::"+name(), ".", "..")
+			if res == "." || res == ".." {
+				// Followed by .( they'd read as .. or a call.
+				return res
+			}
+			switch r.Intn(5) {
+			case 0:
+				res += fmt.Sprintf("[%s]", pick(number(), number()+":"+number(), "::2", ":-1", number()+","+number()))
+			case 1:
+				res += fmt.Sprintf(".(%s)", predicate())
+			case 2:
+				res += "@" + pick(name(), "*")
+			}
+			return res
+		}
+	)
+
+	path := func() string {
+		res := pick("/", "//", "") + step()
+		for i := r.Intn(4); i > 0; i-- {
+			res += "/" + step()
+		}
+		return res
+	}
+
+	res := path()
+	if r.Intn(4) == 0 {
+		res += "|" + path()
+	}
+	return res
+}
+
+func Test_FormatRoundTrip(t *testing.T) {
+	for _, formatter := range []PathFormatter{{}, {Spacing: true}} {
+		f := func(q Query) bool {
+			expr := parse(t, string(q))
+			res, err := formatter.Format(expr)
+			if err != nil {
+				t.Errorf("%s: %v", q, err)
+				return false
+			}
+			if again := parse(t, res); !reflect.DeepEqual(again, expr) {
+				t.Errorf("%s: formatted as %s, expected %v, got %v", q, res, expr, again)
+				return false
+			}
+			return true
+		}
+		if err := quick.Check(f, nil); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func Test_FormatRoundTripForQuotedNamesAndStrings(t *testing.T) {
+	f := func(a string) bool {
+		expr := parse(t, fmt.Sprintf("/%q.(@Name==%q)", a+"x", a))
+		res, err := Format(expr)
+		if err != nil {
+			t.Error(err)
+			return false
+		}
+		return reflect.DeepEqual(parse(t, res), expr)
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_ExpressionsJSONRoundTrip(t *testing.T) {
+	f := func(q Query) bool {
+		expr := parse(t, string(q))
+		data, err := expressions.Encode(expr)
+		if err != nil {
+			t.Errorf("%s: %v", q, err)
+			return false
+		}
+		res, err := expressions.Decode(data)
+		if err != nil {
+			t.Errorf("%s: %v", q, err)
+			return false
+		}
+		if !reflect.DeepEqual(res, expr) {
+			t.Errorf("%s: encoded as %s, expected %v, got %v", q, data, expr, res)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_ExpressionsJSON(t *testing.T) {
+	data, err := json.Marshal(parse(t, `/event[1:].(@A=="x")`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"type":"Descendants","operand":{"type":"Instance",` +
+		`"left":{"type":"IndexAccess","left":{"type":"Name","value":"event"},` +
+		`"right":{"type":"Slice","start":{"type":"Number","value":1}}},` +
+		`"right":{"type":"Group","list":[{"type":"Equality",` +
+		`"left":{"type":"Attribute","operand":{"type":"Name","value":"A"}},` +
+		`"right":{"type":"String","value":"x"}}]}}}`
+	if string(data) != expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+
+	for _, data := range []string{
+		`null`,
+		`{"type":"Unknown"}`,
+		`{"type":"Equality","left":{"type":"Name","value":"A"}}`,
+		`{"type":"Name"}`,
+		`{"type":"Axis","axis":"sideways","test":{"type":"Wildcard"}}`,
+	} {
+		if _, err := expressions.Decode([]byte(data)); err == nil {
+			t.Errorf("%s: expected error", data)
+		}
+	}
+	if _, err := expressions.Encode(hashType{"col"}); err != expressions.ErrUnknownExpressionType {
+		t.Errorf("expected %v, got %v", expressions.ErrUnknownExpressionType, err)
+	}
+
+	// An attribute without a name still round trips.
+	attribute := expressions.MakePathAttribute()
+	if data, err = expressions.Encode(attribute); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := expressions.Decode(data); err != nil || !expressions.Equal(res, attribute) {
+		t.Errorf("expected %v, got %v, %v", attribute, res, err)
+	}
+}
+
+func Test_ExpressionsEqualAndHash(t *testing.T) {
+	for _, test := range []struct {
+		a, b  string
+		equal bool
+	}{
+		{`/event.(@A==1 and not @B)`, `/ event.( @A == 1 && !@B )`, true},
+		{`/event[::2]`, `/event[ : : 2 ]`, true},
+		{`/"event"`, `/event`, true},
+		{`/event.(@A==1.0000001)`, `/event.(@A==1.0000002)`, false},
+		{`/event[1:]`, `/event[:1]`, false},
+		{`/event.(@A=="1")`, `/event.(@A==1)`, false},
+		{`/event.(@A<1)`, `/event.(@A<=1)`, false},
+		{`child::event`, `parent::event`, false},
+		{`/event|colour`, `/event|//colour`, false},
+		{`contains(@A,1)`, `contains(@A,1,2)`, false},
+	} {
+		a, b := parse(t, test.a), parse(t, test.b)
+		if res := expressions.Equal(a, b); res != test.equal {
+			t.Errorf("%s, %s: expected %t, got %t", test.a, test.b, test.equal, res)
+		}
+		if res := expressions.Hash(a) == expressions.Hash(b); res != test.equal {
+			t.Errorf("%s, %s: expected hashes equal to be %t, got %t", test.a, test.b, test.equal, res)
+		}
+	}
+
+	var (
+		a = expressions.MakePathError(errors.New("Bad"), expressions.MakePathName("a"))
+		b = expressions.MakePathError(errors.New("Bad"), expressions.MakePathName("a"))
+	)
+	if !expressions.Equal(a, b) || expressions.Hash(a) != expressions.Hash(b) {
+		t.Errorf("expected %v to equal %v", a, b)
+	}
+	if expressions.Equal(hashType{"a"}, hashType{"b"}) || !expressions.Equal(hashType{"a"}, hashType{"a"}) {
+		t.Errorf("expected custom expressions to be compared by value")
+	}
+}
+
+func Test_ExpressionsEqualAndHashAfterFormatting(t *testing.T) {
+	f := func(q Query) bool {
+		expr := parse(t, string(q))
+		res, err := PathFormatter{Spacing: true}.Format(expr)
+		if err != nil {
+			t.Errorf("%s: %v", q, err)
+			return false
+		}
+		again := parse(t, res)
+		return expressions.Equal(again, expr) && expressions.Hash(again) == expressions.Hash(expr)
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
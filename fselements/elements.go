@@ -0,0 +1,130 @@
+// Package fselements exposes the directories and files of an fs.FS as
+// elements, so that they can be queried with a path.
+//
+// The root directory is named by the name given to MakeElement, which isn't
+// part of a query, as a path starts with the children of the element it's
+// executed on. For MakeElement("root", fsys) the query is /src/*.(@ext=="go"),
+// not /root/src/..., whilst . selects the root itself. The ID of the root is
+// ".", and the ID of every other directory and file is its path within the
+// file system.
+package fselements
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+// element is a directory or file, named by its base name. Directories are only
+// read when their children are asked for, any directory that can't be read
+// has no children.
+//
+// The attributes are name, path, ext (the extension without the dot), dir,
+// size, mode and modtime (in RFC 3339 UTC, so that it compares in order). The
+// last three are missing when the file info can't be read.
+type element struct {
+	fsys   fs.FS
+	name   string
+	path   string
+	entry  fs.DirEntry
+	parent *element
+}
+
+// MakeElement wraps the root directory of the file system.
+func MakeElement(name string, fsys fs.FS) s.Element {
+	return &element{
+		fsys: fsys,
+		name: name,
+		path: ".",
+	}
+}
+
+func (e *element) Name() string {
+	return e.name
+}
+
+// ID is the path of the directory or file within the file system.
+func (e *element) ID() string {
+	return e.path
+}
+
+func (e *element) Parent() s.Element {
+	if e.parent == nil {
+		return nil
+	}
+	return e.parent
+}
+
+func (e *element) Children() []s.Element {
+	res := make([]s.Element, 0)
+	if !e.dir() {
+		return res
+	}
+
+	entries, err := fs.ReadDir(e.fsys, e.path)
+	if err != nil {
+		return res
+	}
+	for _, v := range entries {
+		res = append(res, &element{
+			fsys:   e.fsys,
+			name:   v.Name(),
+			path:   path.Join(e.path, v.Name()),
+			entry:  v,
+			parent: e,
+		})
+	}
+	return res
+}
+
+func (e *element) Attribute(name string) (interface{}, bool) {
+	switch name {
+	case "name":
+		return e.name, true
+	case "path":
+		return e.path, true
+	case "ext":
+		return strings.TrimPrefix(path.Ext(e.name), "."), true
+	case "dir":
+		return e.dir(), true
+	}
+
+	info, err := e.info()
+	if err != nil {
+		return nil, false
+	}
+	switch name {
+	case "size":
+		return info.Size(), true
+	case "mode":
+		return info.Mode().String(), true
+	case "modtime":
+		return info.ModTime().UTC().Format(time.RFC3339), true
+	}
+	return nil, false
+}
+
+func (e *element) Attributes() []string {
+	if _, err := e.info(); err != nil {
+		return []string{"name", "path", "ext", "dir"}
+	}
+	return []string{"name", "path", "ext", "dir", "size", "mode", "modtime"}
+}
+
+func (e *element) dir() bool {
+	if e.entry != nil {
+		return e.entry.IsDir()
+	}
+	info, err := e.info()
+	return err == nil && info.IsDir()
+}
+
+func (e *element) info() (fs.FileInfo, error) {
+	if e.entry != nil {
+		return e.entry.Info()
+	}
+	return fs.Stat(e.fsys, e.path)
+}
@@ -0,0 +1,134 @@
+package fselements_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/SimonRichardson/cilli"
+	"github.com/SimonRichardson/cilli/fselements"
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+func execute(t *testing.T, dsl string, fsys fs.FS) []string {
+	lex := cilli.NewPathLexer(dsl).With(s.PathTokenTypes())
+	expr, err := cilli.NewPathParser(lex.Iter()).ParseExpression()
+	if err != nil {
+		t.Fatalf("%s: %v", dsl, err)
+	}
+	res, err := cilli.NewPath(expr).Execute(fselements.MakeElement("root", fsys))
+	if err != nil {
+		t.Fatalf("%s: %v", dsl, err)
+	}
+	var paths []string
+	for _, v := range res {
+		paths = append(paths, v.(s.Identifier).ID())
+	}
+	return paths
+}
+
+func Test_FSElements(t *testing.T) {
+	var (
+		large = []byte(strings.Repeat("x", 10001))
+		small = []byte("package x")
+		date  = time.Date(2017, 3, 10, 23, 0, 0, 0, time.UTC)
+		fsys  = fstest.MapFS{
+			"src/large.go":          {Data: large, ModTime: date},
+			"src/small.go":          {Data: small, ModTime: date},
+			"src/large.txt":         {Data: large, ModTime: date},
+			"src/nested/src/big.go": {Data: large, ModTime: date.AddDate(1, 0, 0)},
+			"docs/src.md":           {Data: small, ModTime: date},
+		}
+	)
+
+	for _, test := range []struct {
+		dsl   string
+		paths []string
+	}{
+		{`//src/*.(@ext=="go" && @size>10000)`, []string{"src/large.go", "src/nested/src/big.go"}},
+		{`/src/*.(@dir==true)`, []string{"src/nested"}},
+		{`//*.(@modtime>"2018")`, []string{"src/nested/src/big.go"}},
+		{`/docs/*.(@name=="src.md")/..`, []string{"docs"}},
+		{`/src/"small.go"`, []string{"src/small.go"}},
+		{`.`, []string{"."}},
+	} {
+		if res := execute(t, test.dsl, fsys); !reflect.DeepEqual(res, test.paths) {
+			t.Errorf("%s: expected %v, got %v", test.dsl, test.paths, res)
+		}
+	}
+}
+
+func Test_FSElementsWithZip(t *testing.T) {
+	var (
+		buffer bytes.Buffer
+		writer = zip.NewWriter(&buffer)
+	)
+	for name, data := range map[string]string{
+		"src/main.go":   "package main",
+		"src/README.md": "readme",
+	} {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(data))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"src/main.go"}
+	if res := execute(t, `//src/*.(@ext=="go" && @size==12)`, reader); !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected %v, got %v", expected, res)
+	}
+}
+
+// infoFS fails to read the file info of any entry of a directory.
+type infoFS struct {
+	fstest.MapFS
+}
+
+func (f infoFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := f.MapFS.ReadDir(name)
+	for k, v := range entries {
+		entries[k] = infoEntry{v}
+	}
+	return entries, err
+}
+
+type infoEntry struct {
+	fs.DirEntry
+}
+
+func (infoEntry) Info() (fs.FileInfo, error) {
+	return nil, errors.New("no info")
+}
+
+func Test_FSElementsWithoutInfo(t *testing.T) {
+	var (
+		root  = fselements.MakeElement("root", infoFS{fstest.MapFS{"main.go": {Data: []byte("x")}}})
+		file  = root.Children()[0].(s.Attributes)
+		names = file.Attributes()
+	)
+	if expected := []string{"name", "path", "ext", "dir"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+	for _, name := range names {
+		if _, ok := file.Attribute(name); !ok {
+			t.Errorf("%s: expected a value", name)
+		}
+	}
+	if value, ok := file.Attribute("size"); ok {
+		t.Errorf("expected no size, got %v", value)
+	}
+}
@@ -0,0 +1,231 @@
+package cilli
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+var (
+	ErrUnknownFunction = errors.New("Unknown Function")
+	ErrInvalidArity    = errors.New("Invalid Arity")
+	ErrInvalidArgument = errors.New("Invalid Argument")
+)
+
+type PathArgumentType int
+
+const (
+	PATAny PathArgumentType = iota
+	PATString
+	PATNumber
+	PATBoolean
+	PATElements
+)
+
+func (p PathArgumentType) String() string {
+	switch p {
+	case PATAny:
+		return "Any"
+	case PATString:
+		return "String"
+	case PATNumber:
+		return "Number"
+	case PATBoolean:
+		return "Boolean"
+	case PATElements:
+		return "Elements"
+	}
+	return ""
+}
+
+// PathFunction describes a function that can be called from within a path.
+// Arguments are coerced to the declared types before Call is invoked with the
+// context element. When Variadic is set the last argument can be repeated.
+type PathFunction struct {
+	Arguments []PathArgumentType
+	Variadic  bool
+	Call      func(s.Element, []interface{}) (interface{}, error)
+}
+
+func (p PathFunction) accepts(num int) bool {
+	if p.Variadic {
+		return num >= len(p.Arguments)
+	}
+	return num == len(p.Arguments)
+}
+
+func (p PathFunction) argument(index int) PathArgumentType {
+	if num := len(p.Arguments); index >= num {
+		if p.Variadic && num > 0 {
+			return p.Arguments[num-1]
+		}
+		return PATAny
+	}
+	return p.Arguments[index]
+}
+
+type PathFunctions struct {
+	functions map[string]PathFunction
+}
+
+func NewPathFunctions() *PathFunctions {
+	return &PathFunctions{
+		functions: make(map[string]PathFunction),
+	}
+}
+
+func (p *PathFunctions) Register(name string, fn PathFunction) *PathFunctions {
+	p.functions[name] = fn
+	return p
+}
+
+func (p *PathFunctions) Lookup(name string) (PathFunction, bool) {
+	if p == nil {
+		return PathFunction{}, false
+	}
+	fn, ok := p.functions[name]
+	return fn, ok
+}
+
+// StandardPathFunctions returns a new registry containing the built-in string,
+// numeric and node-set functions.
+func StandardPathFunctions() *PathFunctions {
+	return NewPathFunctions().
+		Register("contains", stringPredicate(strings.Contains)).
+		Register("starts_with", stringPredicate(strings.HasPrefix)).
+		Register("ends_with", stringPredicate(strings.HasSuffix)).
+		Register("matches", matches()).
+		Register("lower", stringFunction(strings.ToLower)).
+		Register("upper", stringFunction(strings.ToUpper)).
+		Register("trim", stringFunction(strings.TrimSpace)).
+		Register("length", PathFunction{
+			Arguments: []PathArgumentType{PATString},
+			Call: func(e s.Element, args []interface{}) (interface{}, error) {
+				return float64(utf8.RuneCountInString(args[0].(string))), nil
+			},
+		}).
+		Register("concat", PathFunction{
+			Arguments: []PathArgumentType{PATString, PATString},
+			Variadic:  true,
+			Call: func(e s.Element, args []interface{}) (interface{}, error) {
+				res := make([]string, len(args))
+				for k, v := range args {
+					res[k] = v.(string)
+				}
+				return strings.Join(res, ""), nil
+			},
+		}).
+		Register("string", PathFunction{
+			Arguments: []PathArgumentType{PATString},
+			Call: func(e s.Element, args []interface{}) (interface{}, error) {
+				return args[0], nil
+			},
+		}).
+		Register("number", PathFunction{
+			Arguments: []PathArgumentType{PATNumber},
+			Call: func(e s.Element, args []interface{}) (interface{}, error) {
+				return args[0], nil
+			},
+		}).
+		Register("boolean", PathFunction{
+			Arguments: []PathArgumentType{PATBoolean},
+			Call: func(e s.Element, args []interface{}) (interface{}, error) {
+				return args[0], nil
+			},
+		}).
+		Register("abs", numberFunction(math.Abs)).
+		Register("ceil", numberFunction(math.Ceil)).
+		Register("floor", numberFunction(math.Floor)).
+		Register("round", numberFunction(math.Round)).
+		Register("count", PathFunction{
+			Arguments: []PathArgumentType{PATElements},
+			Call: func(e s.Element, args []interface{}) (interface{}, error) {
+				return float64(len(args[0].([]s.Element))), nil
+			},
+		}).
+		Register("empty", PathFunction{
+			Arguments: []PathArgumentType{PATElements},
+			Call: func(e s.Element, args []interface{}) (interface{}, error) {
+				return len(args[0].([]s.Element)) == 0, nil
+			},
+		}).
+		Register("exists", PathFunction{
+			Arguments: []PathArgumentType{PATElements},
+			Call: func(e s.Element, args []interface{}) (interface{}, error) {
+				return len(args[0].([]s.Element)) > 0, nil
+			},
+		}).
+		Register("name", PathFunction{
+			Call: func(e s.Element, args []interface{}) (interface{}, error) {
+				return e.Name(), nil
+			},
+		})
+}
+
+// maxPatterns is the number of compiled patterns matches keeps, before they're
+// all dropped and compiled again.
+const maxPatterns = 64
+
+// matches compiles each pattern once, as the same pattern is usually matched
+// against every element.
+func matches() PathFunction {
+	var (
+		mutex    sync.Mutex
+		patterns = make(map[string]*regexp.Regexp)
+	)
+	return PathFunction{
+		Arguments: []PathArgumentType{PATString, PATString},
+		Call: func(e s.Element, args []interface{}) (interface{}, error) {
+			pattern := args[1].(string)
+
+			mutex.Lock()
+			re, ok := patterns[pattern]
+			if !ok {
+				var err error
+				if re, err = regexp.Compile(pattern); err != nil {
+					mutex.Unlock()
+					return nil, err
+				}
+				if len(patterns) >= maxPatterns {
+					patterns = make(map[string]*regexp.Regexp)
+				}
+				patterns[pattern] = re
+			}
+			mutex.Unlock()
+
+			return re.MatchString(args[0].(string)), nil
+		},
+	}
+}
+
+func stringPredicate(fn func(string, string) bool) PathFunction {
+	return PathFunction{
+		Arguments: []PathArgumentType{PATString, PATString},
+		Call: func(e s.Element, args []interface{}) (interface{}, error) {
+			return fn(args[0].(string), args[1].(string)), nil
+		},
+	}
+}
+
+func stringFunction(fn func(string) string) PathFunction {
+	return PathFunction{
+		Arguments: []PathArgumentType{PATString},
+		Call: func(e s.Element, args []interface{}) (interface{}, error) {
+			return fn(args[0].(string)), nil
+		},
+	}
+}
+
+func numberFunction(fn func(float64) float64) PathFunction {
+	return PathFunction{
+		Arguments: []PathArgumentType{PATNumber},
+		Call: func(e s.Element, args []interface{}) (interface{}, error) {
+			return fn(args[0].(float64)), nil
+		},
+	}
+}
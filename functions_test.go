@@ -0,0 +1,156 @@
+package cilli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SimonRichardson/cilli/expressions"
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+type titledElement struct {
+	element
+	title string
+}
+
+// MakeTitledElements returns events with as many children as their index.
+func MakeTitledElements(titles ...string) []s.Element {
+	res := make([]s.Element, len(titles))
+	for k, v := range titles {
+		num := uint(k)
+		res[k] = titledElement{
+			element: element{"event", func() []s.Element {
+				return MakeElements("child", num)
+			}},
+			title: v,
+		}
+	}
+	return res
+}
+
+func title(elem s.Element, prop string) (interface{}, bool) {
+	if x, ok := elem.(titledElement); ok && prop == "Title" {
+		return x.title, true
+	}
+	return nil, false
+}
+
+func execute(t *testing.T, dsl string, path func(s.PathExpression) *Path) ([]s.Element, error) {
+	var (
+		lex       = NewPathLexer(dsl).With(s.PathTokenTypes())
+		parser    = NewPathParser(lex.Iter())
+		expr, err = parser.ParseExpression()
+	)
+	if err != nil {
+		t.Fatalf("%s: %v", dsl, err)
+	}
+
+	return path(expr).Execute(MakeElement("root", func() []s.Element {
+		return MakeTitledElements("outage in eu", "all good", "outage in us", "Tmp")
+	}))
+}
+
+func Test_PathParserWithTypesForMethodCall(t *testing.T) {
+	var (
+		lex      = NewPathLexer("node.(contains(@Title, \"outage\") && count(/child)>2)").With(s.PathTokenTypes())
+		parser   = NewPathParser(lex.Iter())
+		res, err = parser.ParseExpression()
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := expressions.MakePathInstance(
+		expressions.MakePathName("node"),
+		expressions.MakePathGroup([]s.PathExpression{
+			expressions.MakePathLogicalAnd(
+				expressions.MakePathMethodCall(
+					expressions.MakePathName("contains"),
+					[]s.PathExpression{
+						expressions.MakePathNamedAttribute(expressions.MakePathName("Title")),
+						expressions.MakePathString("outage"),
+					},
+				),
+				expressions.MakePathGreaterThan(
+					expressions.MakePathMethodCall(
+						expressions.MakePathName("count"),
+						[]s.PathExpression{
+							expressions.MakePathDescendants(s.PDTContext, expressions.MakePathName("child")),
+						},
+					),
+					expressions.MakePathNumber(2),
+				),
+			),
+		}),
+	)
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected %v, got %v", expected, res)
+	}
+}
+
+func Test_PathExecuteWithStandardFunctions(t *testing.T) {
+	for dsl, expected := range map[string]int{
+		"/event.(contains(name(), \"ven\"))":                        4,
+		"/event.(!starts_with(name(), \"ev\"))":                     0,
+		"/event.(ends_with(upper(name()), \"NT\"))":                 4,
+		"/event.(count(/child) > 2)":                                1,
+		"/event.(count(/child) >= 1 && length(name()) == 5)":        3,
+		"/event.(exists(/child))":                                   3,
+		"/event.(concat(name(), \"!\") == \"event!\")":              4,
+		"/event.(name() == \"event\")":                              4,
+		"/event.(matches(name(), \"^e.+t$\") && count(/child) < 2)": 2,
+		"/event.(abs(count(/child)) >= ceil(1.5))":                  2,
+	} {
+		res, err := execute(t, dsl, NewPath)
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+
+		if len(res) != expected {
+			t.Errorf("%s: expected %d, got %d", dsl, expected, len(res))
+		}
+	}
+}
+
+func Test_PathExecuteWithRegisteredFunction(t *testing.T) {
+	functions := StandardPathFunctions().Register("has_prefix_fold", PathFunction{
+		Arguments: []PathArgumentType{PATString, PATString},
+		Call: func(e s.Element, args []interface{}) (interface{}, error) {
+			return strings.HasPrefix(strings.ToLower(args[0].(string)), strings.ToLower(args[1].(string))), nil
+		},
+	})
+
+	res, err := execute(t, "/event.(has_prefix_fold(name(), \"EV\") && count(/child) == 1)", func(expr s.PathExpression) *Path {
+		return NewPath(expr).WithFunctions(functions)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 1 {
+		t.Errorf("expected 1, got %d", len(res))
+	}
+}
+
+func Test_PathExecuteWithInvalidFunctions(t *testing.T) {
+	for dsl, expected := range map[string]error{
+		"/event.(unknown(name()))":           ErrUnknownFunction,
+		"/event.(contains(name()))":          ErrInvalidArity,
+		"/event.(name(/child))":              ErrInvalidArity,
+		"/event.(lower(name(), \"a\"))":      ErrInvalidArity,
+		"/event.(concat(name()))":            ErrInvalidArity,
+		"/event.(count(name()) > 1)":         ErrInvalidArgument,
+		"/event.(abs(\"not a number\") > 1)": ErrInvalidArgument,
+	} {
+		_, err := execute(t, dsl, NewPath)
+		if err != expected {
+			t.Errorf("%s: expected %v, got %v", dsl, expected, err)
+		}
+	}
+
+	// The error from compiling the pattern is returned.
+	if _, err := execute(t, "/event.(matches(name(), \"(\"))", NewPath); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,165 @@
+// Package jsonelements exposes documents decoded by encoding/json as elements,
+// so that they can be queried with a path.
+package jsonelements
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+// ValueAttribute is the attribute holding the value of a scalar found within
+// an array, as it has no key of its own.
+const ValueAttribute = "value"
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// element wraps a decoded value. Objects have a child for every key holding an
+// object and for every item of an array held by a key, named after the key,
+// and an attribute for every key holding a scalar.
+type element struct {
+	name    string
+	value   interface{}
+	pointer string
+	parent  *element
+}
+
+// MakeElement wraps the value decoded by encoding/json as the root element.
+// The items of a root array are named after the root.
+func MakeElement(name string, value interface{}) s.Element {
+	return &element{
+		name:  name,
+		value: value,
+	}
+}
+
+// Decode reads the next value from the decoder and wraps it, so a stream of
+// documents can be queried one at a time.
+func Decode(name string, decoder *json.Decoder) (s.Element, error) {
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return MakeElement(name, value), nil
+}
+
+func (e *element) Name() string {
+	return e.name
+}
+
+// ID is the JSON pointer of the value within the document.
+func (e *element) ID() string {
+	return e.pointer
+}
+
+func (e *element) Parent() s.Element {
+	if e.parent == nil {
+		return nil
+	}
+	return e.parent
+}
+
+// Value returns the value the element wraps.
+func (e *element) Value() interface{} {
+	return e.value
+}
+
+func (e *element) Children() []s.Element {
+	res := make([]s.Element, 0)
+	switch x := e.value.(type) {
+	case map[string]interface{}:
+		for _, k := range keys(x) {
+			switch y := x[k].(type) {
+			case map[string]interface{}:
+				res = append(res, e.child(k, k, y))
+			case []interface{}:
+				res = append(res, e.items(k, y, e.child(k, k, y))...)
+			}
+		}
+	case []interface{}:
+		res = append(res, e.items(e.name, x, e)...)
+	}
+	return res
+}
+
+// items creates a child for every item of the array, the container holds the
+// pointer of the array.
+func (e *element) items(name string, values []interface{}, container *element) []s.Element {
+	res := make([]s.Element, len(values))
+	for k, v := range values {
+		child := container.child(name, strconv.Itoa(k), v)
+		child.parent = e
+		res[k] = child
+	}
+	return res
+}
+
+func (e *element) child(name, key string, value interface{}) *element {
+	return &element{
+		name:    name,
+		value:   value,
+		pointer: e.pointer + "/" + pointerEscaper.Replace(key),
+		parent:  e,
+	}
+}
+
+func (e *element) Attribute(name string) (interface{}, bool) {
+	switch x := e.value.(type) {
+	case map[string]interface{}:
+		if value, ok := x[name]; ok && scalar(value) {
+			return number(value), true
+		}
+	default:
+		if name == ValueAttribute && scalar(x) {
+			return number(x), true
+		}
+	}
+	return nil, false
+}
+
+func (e *element) Attributes() []string {
+	switch x := e.value.(type) {
+	case map[string]interface{}:
+		res := make([]string, 0, len(x))
+		for _, k := range keys(x) {
+			if scalar(x[k]) {
+				res = append(res, k)
+			}
+		}
+		return res
+	case []interface{}:
+		return []string{}
+	}
+	return []string{ValueAttribute}
+}
+
+func keys(values map[string]interface{}) []string {
+	res := make([]string, 0, len(values))
+	for k := range values {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func scalar(value interface{}) bool {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return true
+}
+
+// number turns numbers decoded with UseNumber into float64, so they compare
+// numerically.
+func number(value interface{}) interface{} {
+	if x, ok := value.(json.Number); ok {
+		if res, err := x.Float64(); err == nil {
+			return res
+		}
+	}
+	return value
+}
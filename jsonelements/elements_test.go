@@ -0,0 +1,106 @@
+package jsonelements_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SimonRichardson/cilli"
+	"github.com/SimonRichardson/cilli/jsonelements"
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+const jsonDocument = `{
+	"event": [
+		{"Date": "2017-03-10T23:00:00Z", "colour": [{"Red": 20}, {"Red": 10}]},
+		{"Date": "2018-01-01T00:00:00Z", "colour": {"Red": 20}, "tags": ["a", "b"]}
+	],
+	"a/b": {"c~d": null}
+}`
+
+func execute(t *testing.T, dsl string, element s.Element) (ids []string, values []interface{}) {
+	lex := cilli.NewPathLexer(dsl).With(s.PathTokenTypes())
+	expr, err := cilli.NewPathParser(lex.Iter()).ParseExpression()
+	if err != nil {
+		t.Fatalf("%s: %v", dsl, err)
+	}
+	res, err := cilli.NewPath(expr).ExecuteValues(element)
+	if err != nil {
+		t.Fatalf("%s: %v", dsl, err)
+	}
+	for _, v := range res.Elements() {
+		ids = append(ids, v.(s.Identifier).ID())
+	}
+	return ids, res.Values()
+}
+
+func Test_JSONElements(t *testing.T) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(jsonDocument), &value); err != nil {
+		t.Fatal(err)
+	}
+	root := jsonelements.MakeElement("root", value)
+
+	for _, test := range []struct {
+		dsl    string
+		ids    []string
+		values []interface{}
+	}{
+		{
+			dsl: `/event.(@Date=="2017-03-10T23:00:00Z")/colour.(@Red==20)`,
+			ids: []string{"/event/0/colour/0"},
+		},
+		{
+			dsl: `/event/colour.(@Red==20)`,
+			ids: []string{"/event/0/colour/0", "/event/1/colour"},
+		},
+		{
+			dsl:    `/event[-1]/@Date`,
+			values: []interface{}{"2018-01-01T00:00:00Z"},
+		},
+		{
+			dsl: `/event/tags.(@value=="b")`,
+			ids: []string{"/event/1/tags/1"},
+		},
+		{
+			dsl: `/event/colour/..`,
+			ids: []string{"/event/0", "/event/1"},
+		},
+		{
+			dsl:    `/"a/b"@*`,
+			values: []interface{}{nil},
+		},
+		{
+			dsl: `/"a/b"`,
+			ids: []string{"/a~1b"},
+		},
+	} {
+		ids, values := execute(t, test.dsl, root)
+		if !reflect.DeepEqual(ids, test.ids) {
+			t.Errorf("%s: expected %v, got %v", test.dsl, test.ids, ids)
+		}
+		if test.values != nil && !reflect.DeepEqual(values, test.values) {
+			t.Errorf("%s: expected %v, got %v", test.dsl, test.values, values)
+		}
+	}
+}
+
+func Test_JSONElementsDecode(t *testing.T) {
+	var (
+		decoder = json.NewDecoder(strings.NewReader(`{"item": {"n": 1}} {"item": {"n": 2}} [{"n": 3}]`))
+		counts  []int
+	)
+	decoder.UseNumber()
+	for decoder.More() {
+		element, err := jsonelements.Decode("item", decoder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids, _ := execute(t, `/item.(@n>1)`, element)
+		counts = append(counts, len(ids))
+	}
+	if expected := []int{0, 1, 1}; !reflect.DeepEqual(counts, expected) {
+		t.Errorf("expected %v, got %v", expected, counts)
+	}
+}
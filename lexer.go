@@ -2,53 +2,143 @@ package cilli
 
 import (
 	"bytes"
-	"fmt"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	s "github.com/SimonRichardson/cilli/selectors"
 )
 
+var (
+	ErrInvalidCharacter    = errors.New("Invalid Character")
+	ErrUnterminatedString  = errors.New("Unterminated String")
+	ErrInvalidStringEscape = errors.New("Invalid String Escape")
+)
+
 type PathLexer struct {
-	source string
-	types  map[rune]s.PathTokenType
+	source    string
+	types     map[rune]s.PathTokenType
+	operators map[string]s.PathTokenType
+	keywords  map[string]s.PathTokenType
+	names     string
 }
 
 func NewPathLexer(source string) *PathLexer {
 	return &PathLexer{
-		source: source,
-		types:  make(map[rune]s.PathTokenType),
+		source:    source,
+		types:     make(map[rune]s.PathTokenType),
+		operators: make(map[string]s.PathTokenType),
+		keywords:  make(map[string]s.PathTokenType),
 	}
 }
 
+// With replaces the tokens of the lexer. Operators are matched by the longest
+// one first and keywords are only matched as whole names.
 func (p *PathLexer) With(types []s.PathTokenType) *PathLexer {
-	values := make(map[rune]s.PathTokenType, len(types))
+	res := NewPathLexer(p.source)
+	res.names = p.names
 	for _, v := range types {
-		values[v.Rune()] = v
+		switch {
+		case v.Keyword():
+			res.keywords[v.String()] = v
+		case v.Operator():
+			res.operators[v.String()] = v
+		default:
+			res.types[v.Rune()] = v
+		}
 	}
+	return res
+}
+
+// WithRune maps the rune to the token type, so that custom token types can be
+// added for custom parselets.
+func (p *PathLexer) WithRune(char rune, tokenType s.PathTokenType) *PathLexer {
+	res := p.copy()
+	res.types[char] = tokenType
+	return res
+}
+
+// WithOperator maps the operator to the token type, it takes precedence over
+// any shorter operator or rune it starts with.
+func (p *PathLexer) WithOperator(operator string, tokenType s.PathTokenType) *PathLexer {
+	res := p.copy()
+	res.operators[operator] = tokenType
+	return res
+}
+
+// WithNameRunes allows the runes within names, after the first rune. Names are
+// otherwise made up of letters, digits and underscores. The runes take
+// precedence over any token they're mapped to, except where they start an
+// operator, so allowing : still lets a name be followed by an axis.
+func (p *PathLexer) WithNameRunes(runes ...rune) *PathLexer {
+	res := p.copy()
+	res.names += string(runes)
+	return res
+}
 
+func (p *PathLexer) copy() *PathLexer {
 	res := NewPathLexer(p.source)
-	res.types = values
+	res.names = p.names
+	for k, v := range p.types {
+		res.types[k] = v
+	}
+	for k, v := range p.operators {
+		res.operators[k] = v
+	}
+	for k, v := range p.keywords {
+		res.keywords[k] = v
+	}
 	return res
 }
 
 func (p *PathLexer) Iter() s.PathLexerIterator {
-	return newPathIterator(p.source, p.types)
+	return newPathIterator(p)
 }
 
 type pathLexerIterator struct {
-	reader *strings.Reader
-	types  map[rune]s.PathTokenType
+	source    string
+	lines     []int
+	reader    *strings.Reader
+	types     map[rune]s.PathTokenType
+	operators map[string]s.PathTokenType
+	keywords  map[string]s.PathTokenType
+	names     string
 }
 
-func newPathIterator(source string, types map[rune]s.PathTokenType) *pathLexerIterator {
+func newPathIterator(lexer *PathLexer) *pathLexerIterator {
+	lines := []int{0}
+	for k, v := range lexer.source {
+		if v == '\n' {
+			lines = append(lines, k+1)
+		}
+	}
+
 	return &pathLexerIterator{
-		reader: strings.NewReader(source),
-		types:  types,
+		source:    lexer.source,
+		lines:     lines,
+		reader:    strings.NewReader(lexer.source),
+		types:     lexer.types,
+		operators: lexer.operators,
+		keywords:  lexer.keywords,
+		names:     lexer.names,
 	}
 }
 
+// Source returns the source being tokenized, so that errors can show where
+// they happened.
+func (i *pathLexerIterator) Source() string {
+	return i.source
+}
+
+// HasNext reports if there are any tokens left, trailing spaces are ignored.
 func (i *pathLexerIterator) HasNext() bool {
-	return i.reader.Len() > 0
+	return strings.TrimLeftFunc(i.source[i.offset():], func(r rune) bool {
+		return r <= 32
+	}) != ""
 }
 
 func (i *pathLexerIterator) Next() (s.PathToken, error) {
@@ -56,20 +146,47 @@ func (i *pathLexerIterator) Next() (s.PathToken, error) {
 		token  = s.PTTNull
 		buffer = bytes.NewBufferString("")
 
-		lastChar rune
+		start int
 	)
 
 loop:
 	for {
+		if token == s.PTTNull {
+			start = i.offset()
+		}
+
 		char, _, err := i.reader.ReadRune()
 		if err != nil {
 			return s.PathToken{}, err
 		}
 
+		// Operators
+		if token == s.PTTNull {
+			if tokenType, size, ok := i.operator(start); ok {
+				if _, err := i.reader.Seek(int64(start+size), io.SeekStart); err != nil {
+					return s.PathToken{}, err
+				}
+				return i.token(tokenType, i.source[start:start+size], start), nil
+			}
+		}
+
+		// Axis names are always a single name, even though hyphens aren't
+		// allowed within names by default.
+		if token == s.PTTNull {
+			if size, ok := i.axis(start); ok {
+				if _, err := i.reader.Seek(int64(start+size), io.SeekStart); err != nil {
+					return s.PathToken{}, err
+				}
+				return i.name(i.source[start:start+size], start), nil
+			}
+		}
+
 		// Number!
 		if token == s.PTTNull || token == s.PTTNumber {
 			// Include exponential numbers
-			if (char >= 48 && char <= 57) || char == 45 || char == 46 || (token == s.PTTNumber && (char == 43 || char == 101)) {
+			// A leading dot is only a number when followed by a digit, so that
+			// .. remains two dots.
+			if (char >= 48 && char <= 57) || char == 45 || (char == 46 && (token == s.PTTNumber || i.peekDigit())) || (token == s.PTTNumber && (char == 43 || char == 101)) {
 				if token == s.PTTNull {
 					token = s.PTTNumber
 				}
@@ -78,7 +195,7 @@ loop:
 				if i.HasNext() {
 					continue loop
 				}
-				return s.MakePathToken(token, buffer.String()), nil
+				return i.token(token, buffer.String(), start), nil
 			}
 
 			if token == s.PTTNumber {
@@ -96,7 +213,7 @@ loop:
 								return s.PathToken{}, err
 							}
 
-							return s.MakePathToken(tokenType, string(nan)), nil
+							return i.token(tokenType, string(nan), start), nil
 						}
 					}
 					if err := buffer.UnreadRune(); err != nil {
@@ -108,39 +225,25 @@ loop:
 					return s.PathToken{}, err
 				}
 
-				return s.MakePathToken(token, buffer.String()), nil
+				return i.token(token, buffer.String(), start), nil
 			}
 		}
 
 		// Strings
-		if (token == s.PTTNull && char == 34) || token == s.PTTString {
-			buffer.WriteRune(char)
-			lastChar = char
-
-			if token == s.PTTString && (char == 34 && lastChar != 92) {
-				return s.MakePathToken(token, buffer.String()), nil
-			}
-
-			if token == s.PTTNull {
-				token = s.PTTString
-			}
-
-			if i.HasNext() {
-				continue loop
-			}
-			return s.MakePathToken(token, buffer.String()), nil
+		if token == s.PTTNull && (char == '"' || char == '\'' || char == '`') {
+			return i.quoted(char, start)
 		}
 
 		// Custom types
 		if token == s.PTTNull {
 			if tokenType, ok := i.types[char]; ok {
-				return s.MakePathToken(tokenType, string(char)), nil
+				return i.token(tokenType, string(char), start), nil
 			}
 		}
 
 		// Named properties that are not strings.
 		if token == s.PTTNull || token == s.PTTName {
-			if (char >= 48 && char <= 57) || (char >= 65 && char <= 90) || (char >= 97 && char <= 122) || char == 95 {
+			if unicode.IsLetter(char) || char == '_' || (token == s.PTTName && i.nameRune(char) && !i.operatorAt(char)) {
 				buffer.WriteRune(char)
 
 				if token == s.PTTNull {
@@ -150,7 +253,7 @@ loop:
 				if i.HasNext() {
 					continue loop
 				}
-				return s.MakePathToken(token, buffer.String()), nil
+				return i.name(buffer.String(), start), nil
 			}
 
 			if token == s.PTTName {
@@ -158,7 +261,7 @@ loop:
 					return s.PathToken{}, err
 				}
 
-				return s.MakePathToken(token, buffer.String()), nil
+				return i.name(buffer.String(), start), nil
 			}
 		}
 
@@ -171,5 +274,164 @@ loop:
 		break
 	}
 
-	return s.MakePathToken(s.PTTNull, ""), fmt.Errorf("Nothing found.")
+	return s.MakePathToken(s.PTTNull, ""), &ParseError{
+		Err:    ErrInvalidCharacter,
+		Source: i.source,
+		Start:  i.position(start),
+		End:    i.position(i.offset()),
+	}
+}
+
+func (i *pathLexerIterator) token(tokenType s.PathTokenType, val string, start int) s.PathToken {
+	return s.MakePathTokenAt(tokenType, val, i.position(start), i.position(i.offset()))
+}
+
+// quoted scans the rest of a string literal, the value of the token is the
+// unquoted string. Raw (`) strings have no escapes and can span lines. The
+// reader is left after the closing quote, even if an escape was invalid.
+func (i *pathLexerIterator) quoted(quote rune, start int) (s.PathToken, error) {
+	var (
+		buffer = bytes.NewBufferString("")
+		offset = i.offset()
+		rest   = i.source[offset:]
+
+		failure error
+	)
+
+	for {
+		char, size := utf8.DecodeRuneInString(rest)
+		if rest == "" || (char == '\n' && quote != '`') {
+			end := len(i.source) - len(rest)
+			if _, err := i.reader.Seek(int64(end), io.SeekStart); err != nil {
+				return s.PathToken{}, err
+			}
+			return s.MakePathToken(s.PTTNull, ""), &ParseError{
+				Err:    ErrUnterminatedString,
+				Source: i.source,
+				Start:  i.position(start),
+				End:    i.position(end),
+			}
+		}
+
+		if char == quote {
+			rest = rest[size:]
+			break
+		}
+		if quote == '`' {
+			buffer.WriteRune(char)
+			rest = rest[size:]
+			continue
+		}
+
+		value, multibyte, tail, err := strconv.UnquoteChar(rest, byte(quote))
+		if err != nil {
+			// Skip over the backslash and the rune after it, so that the rest
+			// of the string is still scanned.
+			at := len(i.source) - len(rest)
+			_, size = utf8.DecodeRuneInString(rest[1:])
+			if failure == nil {
+				failure = &ParseError{
+					Err:    ErrInvalidStringEscape,
+					Source: i.source,
+					Start:  i.position(at),
+					End:    i.position(at + 1 + size),
+				}
+			}
+			rest = rest[1+size:]
+			continue
+		}
+		if value < utf8.RuneSelf || !multibyte {
+			buffer.WriteByte(byte(value))
+		} else {
+			buffer.WriteRune(value)
+		}
+		rest = tail
+	}
+
+	if _, err := i.reader.Seek(int64(len(i.source)-len(rest)), io.SeekStart); err != nil {
+		return s.PathToken{}, err
+	}
+	if failure != nil {
+		return s.MakePathToken(s.PTTNull, ""), failure
+	}
+	return i.token(s.PTTString, buffer.String(), start), nil
+}
+
+// name returns the name token, unless the name is a keyword.
+func (i *pathLexerIterator) name(val string, start int) s.PathToken {
+	if tokenType, ok := i.keywords[val]; ok {
+		return i.token(tokenType, val, start)
+	}
+	return i.token(s.PTTName, val, start)
+}
+
+// nameRune reports if the rune can be within a name.
+func (i *pathLexerIterator) nameRune(char rune) bool {
+	return unicode.IsLetter(char) || unicode.IsDigit(char) || char == '_' || strings.ContainsRune(i.names, char)
+}
+
+// operatorAt reports if the rune just read starts an operator, in which case
+// it ends the name rather than being part of it.
+func (i *pathLexerIterator) operatorAt(char rune) bool {
+	if unicode.IsLetter(char) || unicode.IsDigit(char) || char == '_' {
+		return false
+	}
+	_, _, ok := i.operator(i.offset() - utf8.RuneLen(char))
+	return ok
+}
+
+// axis finds an axis name with a hyphen at the offset, returning its size. It
+// has to be the whole of the name, so following-siblings isn't matched.
+func (i *pathLexerIterator) axis(offset int) (int, bool) {
+	rest := i.source[offset:]
+	for _, v := range s.PathAxisTypes() {
+		name := v.String()
+		if !strings.ContainsRune(name, '-') || !strings.HasPrefix(rest, name) {
+			continue
+		}
+		if char, size := utf8.DecodeRuneInString(rest[len(name):]); size == 0 || !i.nameRune(char) {
+			return len(name), true
+		}
+	}
+	return 0, false
+}
+
+// operator finds the longest operator at the offset, returning its size.
+func (i *pathLexerIterator) operator(offset int) (s.PathTokenType, int, bool) {
+	var (
+		res  s.PathTokenType
+		size int
+	)
+	for k, v := range i.operators {
+		if len(k) > size && strings.HasPrefix(i.source[offset:], k) {
+			res, size = v, len(k)
+		}
+	}
+	return res, size, size > 0
+}
+
+func (i *pathLexerIterator) offset() int {
+	return len(i.source) - i.reader.Len()
+}
+
+func (i *pathLexerIterator) position(offset int) s.PathPosition {
+	line := sort.Search(len(i.lines), func(k int) bool {
+		return i.lines[k] > offset
+	})
+	return s.PathPosition{
+		Offset: offset,
+		Line:   line,
+		Column: utf8.RuneCountInString(i.source[i.lines[line-1]:offset]) + 1,
+	}
+}
+
+func (i *pathLexerIterator) peekDigit() bool {
+	char, _, err := i.reader.ReadRune()
+	if err != nil {
+		return false
+	}
+	if err := i.reader.UnreadRune(); err != nil {
+		return false
+	}
+	return char >= 48 && char <= 57
 }
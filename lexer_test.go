@@ -95,7 +95,7 @@ func Test_PathLexerForString(t *testing.T) {
 			return val.Val()
 		}
 		g = func(a string) string {
-			return fmt.Sprintf("%q", a)
+			return a
 		}
 	)
 
@@ -161,7 +161,7 @@ func Test_PathLexerForStringFloatNamed(t *testing.T) {
 			return fmt.Sprintf("%s%s%s", x, y, z)
 		}
 		g = func(a string, b float64, c Named) string {
-			return fmt.Sprintf("%q%f%s", a, b, c.String())
+			return fmt.Sprintf("%s%f%s", a, b, c.String())
 		}
 	)
 
@@ -194,7 +194,7 @@ func Test_PathLexerForFloatNamedIntString(t *testing.T) {
 			return fmt.Sprintf("%s%s%s%s", v, x, y, z)
 		}
 		g = func(a float64, b Named, c int, d string) string {
-			return fmt.Sprintf("%f%s%d%q", a, b.String(), c, d)
+			return fmt.Sprintf("%f%s%d%s", a, b.String(), c, d)
 		}
 	)
 
@@ -217,7 +217,7 @@ func Test_PathLexerForStringFloatNamedWithSpaces(t *testing.T) {
 			return fmt.Sprintf("%s%s%s", x, y, z)
 		}
 		g = func(a string, b float64, c Named) string {
-			return fmt.Sprintf("%q%f%s", a, b, c.String())
+			return fmt.Sprintf("%s%f%s", a, b, c.String())
 		}
 	)
 
@@ -284,13 +284,13 @@ func Test_PathLexerWithTypesForGroupEquality(t *testing.T) {
 				lex  = NewPathLexer(fmt.Sprintf("/%s.(@Name==%q)", name.String(), name.String())).With(s.PathTokenTypes())
 				iter = lex.Iter()
 
-				args = vals(t, iter, 10)
+				args = vals(t, iter, 9)
 			)
 
 			return fmt.Sprintf(strings.Repeat("%s", len(args)), args...)
 		}
 		g = func(name Named) string {
-			return fmt.Sprintf("/%s.(@Name==%q)", name.String(), name.String())
+			return fmt.Sprintf("/%s.(@Name==%s)", name.String(), name.String())
 		}
 	)
 
@@ -315,13 +315,13 @@ func Test_PathLexerWithTypesForDoubleGroupEquality(t *testing.T) {
 				lex  = NewPathLexer(dsl).With(s.PathTokenTypes())
 				iter = lex.Iter()
 
-				args = vals(t, iter, 20)
+				args = vals(t, iter, 18)
 			)
 
 			return fmt.Sprintf(strings.Repeat("%s", len(args)), args...)
 		}
 		g = func(name Named) string {
-			return fmt.Sprintf("/%s.(@Name==%q)/%s.(@Name==%q)", name.String(), name.String(), name.String(), name.String())
+			return fmt.Sprintf("/%s.(@Name==%s)/%s.(@Name==%s)", name.String(), name.String(), name.String(), name.String())
 		}
 	)
 
@@ -329,3 +329,143 @@ func Test_PathLexerWithTypesForDoubleGroupEquality(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func Test_PathLexerWithPositions(t *testing.T) {
+	var (
+		lex  = NewPathLexer("/event\n  /colour[10]").With(s.PathTokenTypes())
+		iter = lex.Iter()
+	)
+
+	for _, expected := range []struct {
+		val        string
+		start, end s.PathPosition
+	}{
+		{"/", s.PathPosition{Offset: 0, Line: 1, Column: 1}, s.PathPosition{Offset: 1, Line: 1, Column: 2}},
+		{"event", s.PathPosition{Offset: 1, Line: 1, Column: 2}, s.PathPosition{Offset: 6, Line: 1, Column: 7}},
+		{"/", s.PathPosition{Offset: 9, Line: 2, Column: 3}, s.PathPosition{Offset: 10, Line: 2, Column: 4}},
+		{"colour", s.PathPosition{Offset: 10, Line: 2, Column: 4}, s.PathPosition{Offset: 16, Line: 2, Column: 10}},
+		{"[", s.PathPosition{Offset: 16, Line: 2, Column: 10}, s.PathPosition{Offset: 17, Line: 2, Column: 11}},
+		{"10", s.PathPosition{Offset: 17, Line: 2, Column: 11}, s.PathPosition{Offset: 19, Line: 2, Column: 13}},
+		{"]", s.PathPosition{Offset: 19, Line: 2, Column: 13}, s.PathPosition{Offset: 20, Line: 2, Column: 14}},
+	} {
+		token := next(t, iter)
+		if token.Val() != expected.val || token.Start() != expected.start || token.End() != expected.end {
+			t.Errorf("expected %q at %v-%v, got %q at %v-%v",
+				expected.val, expected.start, expected.end,
+				token.Val(), token.Start(), token.End(),
+			)
+		}
+	}
+}
+
+func Test_PathLexerWithOperatorsAndKeywords(t *testing.T) {
+	var (
+		lex  = NewPathLexer("a==b!=c<=d>= e&&f||g::h..|i and not j or in:k<l.").With(s.PathTokenTypes())
+		iter = lex.Iter()
+	)
+
+	for _, expected := range []s.PathTokenType{
+		s.PTTName, s.PTTDoubleEquality, s.PTTName, s.PTTBangEquality,
+		s.PTTName, s.PTTBackArrowEquality, s.PTTName, s.PTTForwardArrowEquality,
+		s.PTTName, s.PTTDoubleAmpersand, s.PTTName, s.PTTDoublePipe,
+		s.PTTName, s.PTTDoubleColon, s.PTTName, s.PTTDoubleDot, s.PTTPipe,
+		s.PTTName, s.PTTAnd, s.PTTNot, s.PTTName, s.PTTOr, s.PTTName, s.PTTColon,
+		s.PTTName, s.PTTBackArrow, s.PTTName, s.PTTDot,
+	} {
+		if token := next(t, iter); token.Type() != expected {
+			t.Errorf("expected %q, got %q (%q)", expected, token.Type(), token.Val())
+		}
+	}
+	if iter.HasNext() {
+		t.Error("expected no more tokens")
+	}
+
+	// Keywords are names without the types.
+	iter = NewPathLexer("and").Iter()
+	if token := next(t, iter); token.Type() != s.PTTName {
+		t.Errorf("expected %q, got %q", s.PTTName, token.Type())
+	}
+
+	// Custom operators win over the shorter operators they start with.
+	const pttTripleEquality s.PathTokenType = 100
+	iter = NewPathLexer("a===b").With(s.PathTokenTypes()).WithOperator("===", pttTripleEquality).Iter()
+	for _, expected := range []s.PathTokenType{s.PTTName, pttTripleEquality, s.PTTName} {
+		if token := next(t, iter); token.Type() != expected {
+			t.Errorf("expected %q, got %q", expected, token.Type())
+		}
+	}
+}
+
+func Test_PathLexerWithStrings(t *testing.T) {
+	for dsl, expected := range map[string]string{
+		`"a\"b"`:        `a"b`,
+		`"a\\"`:         `a\`,
+		`"a\nb"`:        "a\nb",
+		`"été"`:         "été",
+		`'it\'s "b"'`:   `it's "b"`,
+		"`a\\n\"b\"\n`": "a\\n\"b\"\n",
+		`""`:            "",
+	} {
+		token, err := NewPathLexer(dsl).Iter().Next()
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+		if token.Type() != s.PTTString || token.Val() != expected {
+			t.Errorf("%s: expected %q, got %q", dsl, expected, token.Val())
+		}
+	}
+
+	for _, test := range []struct {
+		dsl        string
+		expected   error
+		start, end int
+	}{
+		{`"abc`, ErrUnterminatedString, 0, 4},
+		{"'a\nb'", ErrUnterminatedString, 0, 2},
+		{"`abc", ErrUnterminatedString, 0, 4},
+		{`"a\qb"/c`, ErrInvalidStringEscape, 2, 4},
+	} {
+		iter := NewPathLexer(test.dsl).With(s.PathTokenTypes()).Iter()
+
+		_, err := iter.Next()
+		x, ok := err.(*ParseError)
+		if !ok || x.Err != test.expected || x.Start.Offset != test.start || x.End.Offset != test.end {
+			t.Errorf("%s: expected %v at %d-%d, got %v", test.dsl, test.expected, test.start, test.end, err)
+		}
+	}
+
+	// The rest of the source is still tokenized after an invalid escape.
+	iter := NewPathLexer(`"a\qb"/c`).With(s.PathTokenTypes()).Iter()
+	if _, err := iter.Next(); err == nil {
+		t.Fatal("expected an error")
+	}
+	if token := next(t, iter); token.Type() != s.PTTForwardSlash {
+		t.Errorf("expected %q, got %q", s.PTTForwardSlash, token.Type())
+	}
+}
+
+func Test_PathLexerWithUnicodeNames(t *testing.T) {
+	for _, test := range []struct {
+		lex      *PathLexer
+		expected []string
+	}{
+		{NewPathLexer("größe/名前_1"), []string{"größe", "/", "名前_1"}},
+		{NewPathLexer("@a-1/b-1"), []string{"@", "a", "-1", "/", "b", "-1"}},
+		{NewPathLexer("data-id").WithNameRunes('-'), []string{"data-id"}},
+		{NewPathLexer("following-sibling::x"), []string{"following-sibling", "::", "x"}},
+		{NewPathLexer("preceding-sibling::a-b").WithNameRunes('-'), []string{"preceding-sibling", "::", "a-b"}},
+		{NewPathLexer("xml:lang"), []string{"xml", ":", "lang"}},
+		{NewPathLexer("xml:lang/a.b").WithNameRunes(':', '.'), []string{"xml:lang", "/", "a.b"}},
+		{NewPathLexer("xml:lang/parent::x/a::b").WithNameRunes(':'), []string{"xml:lang", "/", "parent", "::", "x", "/", "a", "::", "b"}},
+	} {
+		iter := test.lex.With(s.PathTokenTypes()).Iter()
+		for _, expected := range test.expected {
+			if token := next(t, iter); token.Val() != expected {
+				t.Errorf("expected %q, got %q", expected, token.Val())
+			}
+		}
+		if iter.HasNext() {
+			t.Errorf("expected no more tokens")
+		}
+	}
+}
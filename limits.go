@@ -0,0 +1,79 @@
+package cilli
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrNodeLimitExceeded   = errors.New("Node Limit Exceeded")
+	ErrDepthLimitExceeded  = errors.New("Depth Limit Exceeded")
+	ErrResultLimitExceeded = errors.New("Result Limit Exceeded")
+)
+
+// PathOptions limits the resources a path can use when it's executed, a zero
+// limit is unlimited. MaxNodes is the number of nodes visited, MaxDepth is how
+// far below the node a descendant walk (//) can go and MaxResults is the number
+// of results returned.
+type PathOptions struct {
+	MaxNodes   int
+	MaxDepth   int
+	MaxResults int
+}
+
+type pathLimits struct {
+	ctx     context.Context
+	options PathOptions
+	visited int
+}
+
+func newPathLimits(ctx context.Context, options PathOptions) *pathLimits {
+	return &pathLimits{
+		ctx:     ctx,
+		options: options,
+	}
+}
+
+// check returns the error of the context once it's been cancelled.
+func (l *pathLimits) check() error {
+	if l == nil {
+		return nil
+	}
+	return l.ctx.Err()
+}
+
+func (l *pathLimits) visit(num int) error {
+	if l == nil {
+		return nil
+	}
+	if l.visited += num; l.options.MaxNodes > 0 && l.visited > l.options.MaxNodes {
+		return ErrNodeLimitExceeded
+	}
+	return nil
+}
+
+func (l *pathLimits) descend(depth int) error {
+	if l != nil && l.options.MaxDepth > 0 && depth > l.options.MaxDepth {
+		return ErrDepthLimitExceeded
+	}
+	return nil
+}
+
+func (l *pathLimits) results(stream resultStream) resultStream {
+	var count int
+	return func() (PathResult, bool, error) {
+		if err := l.check(); err != nil {
+			return PathResult{}, false, err
+		}
+
+		res, ok, err := stream()
+		if err != nil || !ok {
+			return PathResult{}, false, err
+		}
+
+		if count++; l.options.MaxResults > 0 && count > l.options.MaxResults {
+			return PathResult{}, false, ErrResultLimitExceeded
+		}
+		return res, true, nil
+	}
+}
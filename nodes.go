@@ -0,0 +1,238 @@
+package cilli
+
+import (
+	"fmt"
+
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+// pathNode is an element reached whilst running a path. It keeps hold of the
+// node it was reached from, so that the upward and sibling axes work against
+// elements that don't implement s.ParentElement.
+type pathNode struct {
+	element s.Element
+	parent  *pathNode
+	index   int
+	limits  *pathLimits
+}
+
+func makePathNode(element s.Element, limits *pathLimits) *pathNode {
+	return &pathNode{
+		element: element,
+		index:   -1,
+		limits:  limits,
+	}
+}
+
+func (n *pathNode) children() ([]*pathNode, error) {
+	if err := n.limits.check(); err != nil {
+		return nil, err
+	}
+
+	children := n.element.Children()
+	if err := n.limits.visit(len(children)); err != nil {
+		return nil, err
+	}
+
+	res := make([]*pathNode, len(children))
+	for k, v := range children {
+		res[k] = &pathNode{
+			element: v,
+			parent:  n,
+			index:   k,
+			limits:  n.limits,
+		}
+	}
+	return res, nil
+}
+
+// up returns the parent of the node, preferring the tracked parent and falling
+// back to s.ParentElement for nodes above where the path started.
+func (n *pathNode) up() (*pathNode, bool) {
+	if n.parent != nil {
+		return n.parent, true
+	}
+	if x, ok := n.element.(s.ParentElement); ok {
+		if parent := x.Parent(); parent != nil {
+			return makePathNode(parent, n.limits), true
+		}
+	}
+	return nil, false
+}
+
+// siblings returns the children of the parent along with the position of the
+// node within them. Untracked nodes are located by their identity.
+func (n *pathNode) siblings() ([]*pathNode, int, error) {
+	parent, ok := n.up()
+	if !ok {
+		return nil, -1, nil
+	}
+
+	children, err := parent.children()
+	if err != nil {
+		return nil, -1, err
+	}
+	if n.parent != nil {
+		return children, n.index, nil
+	}
+
+	if id, ok := identity(n.element); ok {
+		for k, v := range children {
+			if x, ok := identity(v.element); ok && x == id {
+				return children, k, nil
+			}
+		}
+	}
+	return nil, -1, nil
+}
+
+type position struct {
+	root    *pathNode
+	indexes string
+}
+
+// key returns a comparable key for the node, the same element reached through
+// different nodes shares the key when it has an identity. Otherwise nodes at
+// the same position from the same root share the key.
+func (n *pathNode) key() interface{} {
+	if id, ok := identity(n.element); ok {
+		return id
+	}
+	indexes, root := n.position()
+	return position{root, fmt.Sprint(indexes)}
+}
+
+// precedes reports if the node comes before the other in document order, an
+// ancestor coming before its descendants. Only nodes tracked from the same
+// root can be ordered.
+func (n *pathNode) precedes(other *pathNode) bool {
+	a, root := n.position()
+	b, otherRoot := other.position()
+	if root != otherRoot {
+		return false
+	}
+	for k := 0; k < len(a) && k < len(b); k++ {
+		if a[k] != b[k] {
+			return a[k] < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// position returns the index of the node and of each of its tracked ancestors,
+// starting from the root, along with the root.
+func (n *pathNode) position() ([]int, *pathNode) {
+	var res []int
+	for ; n.parent != nil; n = n.parent {
+		res = append([]int{n.index}, res...)
+	}
+	return res, n
+}
+
+// axis selects the nodes along the axis that match the axis test. Reverse axes
+// are ordered from the nearest node outwards.
+func axis(expression s.PathExpression, nodes nodeStream) nodeStream {
+	expr, ok := expression.(s.Axis)
+	if !ok {
+		return streamOf()
+	}
+
+	var res nodeStream
+	switch expr.Axis() {
+	case s.PAXSelf:
+		res = nodes
+	case s.PAXChild:
+		res = getContextChildren(nodes)
+	case s.PAXDescendant:
+		res = getAllChildren(nodes)
+	case s.PAXDescendantOrSelf:
+		res = expand(nodes, func(node *pathNode) nodeStream {
+			return concat(streamOf(node), getAllChildren(streamOf(node)))
+		})
+	default:
+		res = expand(nodes, func(node *pathNode) nodeStream {
+			res, err := along(expr.Axis(), node)
+			if err != nil {
+				return failed(err)
+			}
+			return streamOf(res...)
+		})
+	}
+
+	return nodeTest(expr.Test(), distinct(res))
+}
+
+// along returns the nodes of the upward and sibling axes for a single node.
+func along(axis s.PathAxisType, node *pathNode) ([]*pathNode, error) {
+	var res []*pathNode
+	switch axis {
+	case s.PAXParent:
+		if parent, ok := node.up(); ok {
+			res = append(res, parent)
+		}
+	case s.PAXAncestor, s.PAXAncestorOrSelf:
+		if axis == s.PAXAncestorOrSelf {
+			res = append(res, node)
+		}
+		for parent, ok := node.up(); ok; parent, ok = parent.up() {
+			res = append(res, parent)
+		}
+	case s.PAXFollowingSibling, s.PAXPrecedingSibling:
+		siblings, index, err := node.siblings()
+		if err != nil || index < 0 {
+			return nil, err
+		}
+
+		if axis == s.PAXFollowingSibling {
+			res = append(res, siblings[index+1:]...)
+			break
+		}
+		for k := index - 1; k >= 0; k-- {
+			res = append(res, siblings[k])
+		}
+	}
+	return res, nil
+}
+
+func nodeTest(expression s.PathExpression, nodes nodeStream) nodeStream {
+	switch expression.Type() {
+	case s.PETWildcard:
+		return nodes
+	case s.PETName:
+		return filterByName(expression, nodes)
+	case s.PETIndexAccess:
+		if x, ok := left(expression); ok {
+			if y, ok := right(expression); ok {
+				return filterByIndex(y, nodeTest(x, nodes))
+			}
+		}
+	}
+	return streamOf()
+}
+
+func distinct(nodes nodeStream) nodeStream {
+	seen := make(map[interface{}]struct{})
+	return filter(nodes, func(node *pathNode) (bool, error) {
+		k := node.key()
+		if _, ok := seen[k]; ok {
+			return false, nil
+		}
+		seen[k] = struct{}{}
+		return true, nil
+	})
+}
+
+// axial reports if the expression starts with an axis, or a custom selection,
+// in which case it applies to the current nodes rather than to their children.
+func (p *Path) axial(expression s.PathExpression) bool {
+	switch expression.Type() {
+	case s.PETAxis:
+		return true
+	case s.PETNameDescendants, s.PETInstance, s.PETbranch:
+		if x, ok := left(expression); ok {
+			return p.axial(x)
+		}
+	}
+	_, ok := p.selector(expression)
+	return ok
+}
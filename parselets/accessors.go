@@ -9,6 +9,8 @@ import (
 
 var (
 	ErrInvalidIndexAccess = errors.New("Invalid Index Access")
+	ErrInvalidMethodCall  = errors.New("Invalid Method Call")
+	ErrInvalidAxis        = errors.New("Invalid Axis")
 )
 
 type pathDescendants struct{}
@@ -24,12 +26,12 @@ func (p pathDescendants) Parse(parser s.PathParser, token s.PathToken) (s.PathEx
 		context = s.PDTAll
 	}
 
-	right, err := parser.ParseExpression()
+	right, err := parseName(parser, s.PPUnion)
 	if err != nil {
 		return nil, err
 	}
 
-	return expressions.MakePathDescendants(context, right), nil
+	return expressions.MakePathDescendants(context, quotedName(right)), nil
 }
 
 type pathNameDescendants struct{}
@@ -39,23 +41,51 @@ func MakePathNameDescendants() s.PathInfixParselet {
 }
 
 func (p pathNameDescendants) Parse(parser s.PathParser, expr s.PathExpression, token s.PathToken) (s.PathExpression, error) {
+	expr = quotedName(expr)
+
 	fn := expressions.MakePathBranch
 	if leftBranch(expr, s.PETName) {
 		fn = expressions.MakePathNameDescendants
 	}
 
-	right, err := parser.ParseExpression()
+	right, err := parseName(parser, s.PPUnion)
 	if err != nil {
 		return nil, err
 	}
 
-	return fn(expr, right), nil
+	return fn(expr, quotedName(right)), nil
 }
 
 func (p pathNameDescendants) Precedence() s.PathPrecedence {
 	return s.PPPostfix
 }
 
+// quotedName treats a string at the start of a step as a quoted name, so that
+// /"weird name"/child selects the children of the weird name elements.
+func quotedName(expr s.PathExpression) s.PathExpression {
+	var fn func(s.PathExpression, s.PathExpression) s.PathExpression
+	switch expr.Type() {
+	case s.PETString:
+		if x, ok := expr.(s.Value); ok {
+			if name, ok := x.Value().(string); ok {
+				return expressions.MakePathName(name)
+			}
+		}
+		return expr
+	case s.PETIndexAccess:
+		fn = expressions.MakePathIndexAccess
+	case s.PETInstance:
+		fn = expressions.MakePathInstance
+	case s.PETInfixAttribute:
+		fn = expressions.MakePathInfixAttribute
+	default:
+		return expr
+	}
+
+	x := expr.(s.Branch)
+	return fn(quotedName(x.Left()), x.Right())
+}
+
 func leftBranch(expr s.PathExpression, value s.PathExpressionType) bool {
 	if expr.Type() == value {
 		return true
@@ -73,12 +103,12 @@ func MakePathBranch() s.PathInfixParselet {
 }
 
 func (p pathBranch) Parse(parser s.PathParser, expr s.PathExpression, token s.PathToken) (s.PathExpression, error) {
-	right, err := parser.ParseExpression()
+	right, err := parseName(parser, s.PPUnion)
 	if err != nil {
 		return nil, err
 	}
 
-	return expressions.MakePathBranch(expr, right), nil
+	return expressions.MakePathBranch(quotedName(expr), quotedName(right)), nil
 }
 
 func (p pathBranch) Precedence() s.PathPrecedence {
@@ -92,21 +122,173 @@ func MakePathIndexAccess() s.PathInfixParselet {
 }
 
 func (p pathIndexAccess) Parse(parser s.PathParser, expr s.PathExpression, token s.PathToken) (s.PathExpression, error) {
-	if !parser.Match(s.PTTRightSquare) {
-		param, err := parser.ParseExpression()
+	if parser.Match(s.PTTRightSquare) {
+		return nil, ErrInvalidIndexAccess
+	}
+
+	param, err := p.index(parser)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case parser.Match(s.PTTDoubleColon):
+		// The end has been omitted, leaving just the step.
+		step, err := p.index(parser)
 		if err != nil {
 			return nil, err
 		}
+		param = expressions.MakePathSlice(param, nil, step)
 
-		if _, err := parser.ConsumeToken(s.PTTRightSquare); err != nil {
+	case parser.Match(s.PTTColon):
+		var end, step s.PathExpression
+		if end, err = p.index(parser); err != nil {
 			return nil, err
 		}
+		if parser.Match(s.PTTColon) {
+			if step, err = p.index(parser); err != nil {
+				return nil, err
+			}
+		}
+		param = expressions.MakePathSlice(param, end, step)
+
+	case param == nil:
+		return nil, ErrInvalidIndexAccess
+
+	case parser.Match(s.PTTComma):
+		params := []s.PathExpression{param}
+		for {
+			index, err := p.index(parser)
+			if err != nil {
+				return nil, err
+			}
+			if index == nil {
+				return nil, ErrInvalidIndexAccess
+			}
+			params = append(params, index)
+
+			if !parser.Match(s.PTTComma) {
+				break
+			}
+		}
+		param = expressions.MakePathIndexList(params)
+	}
+
+	if _, err := parser.ConsumeToken(s.PTTRightSquare); err != nil {
+		return nil, err
+	}
+
+	return expressions.MakePathIndexAccess(expr, param), nil
+}
 
-		return expressions.MakePathIndexAccess(expr, param), nil
+// index parses a single index, returning nil if it has been omitted from a
+// slice.
+func (p pathIndexAccess) index(parser s.PathParser) (s.PathExpression, error) {
+	if check(parser, s.PTTColon) || check(parser, s.PTTDoubleColon) || check(parser, s.PTTRightSquare) {
+		return nil, nil
 	}
-	return nil, ErrInvalidIndexAccess
+	// Bind tighter than an axis, otherwise the double colon of a slice is
+	// mistaken for one.
+	return parser.ParseExpressionBy(s.PPPostfix)
 }
 
 func (p pathIndexAccess) Precedence() s.PathPrecedence {
 	return s.PPCall
 }
+
+type pathMethodCall struct{}
+
+func MakePathMethodCall() s.PathInfixParselet {
+	return pathMethodCall{}
+}
+
+func (p pathMethodCall) Parse(parser s.PathParser, expr s.PathExpression, token s.PathToken) (s.PathExpression, error) {
+	if expr.Type() != s.PETName {
+		return nil, ErrInvalidMethodCall
+	}
+
+	params := make([]s.PathExpression, 0)
+	if !parser.Match(s.PTTRightParen) {
+		for {
+			if !more(parser) {
+				_, err := parser.ConsumeToken(s.PTTRightParen)
+				return nil, err
+			}
+
+			param, err := parser.ParseExpression()
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, param)
+
+			if parser.Match(s.PTTComma) {
+				continue
+			}
+			if _, err := parser.ConsumeToken(s.PTTRightParen); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	return expressions.MakePathMethodCall(expr, params), nil
+}
+
+func (p pathMethodCall) Precedence() s.PathPrecedence {
+	return s.PPCall
+}
+
+type pathSelf struct{}
+
+// MakePathSelf handles both the self (.) and the parent (..) abbreviations.
+func MakePathSelf() s.PathPrefixParselet {
+	return pathSelf{}
+}
+
+func (p pathSelf) Parse(parser s.PathParser, token s.PathToken) (s.PathExpression, error) {
+	axis := s.PAXSelf
+	if token.Type() == s.PTTDoubleDot {
+		axis = s.PAXParent
+	}
+
+	return expressions.MakePathAxis(axis, expressions.MakePathWildcard()), nil
+}
+
+type pathAxis struct{}
+
+func MakePathAxis() s.PathInfixParselet {
+	return pathAxis{}
+}
+
+func (p pathAxis) Parse(parser s.PathParser, expr s.PathExpression, token s.PathToken) (s.PathExpression, error) {
+	axis, ok := axisByName(expr)
+	if !ok {
+		return nil, ErrInvalidAxis
+	}
+
+	test, err := parseName(parser, s.PPPostfix)
+	if err != nil {
+		return nil, err
+	}
+
+	switch test = quotedName(test); test.Type() {
+	case s.PETName, s.PETWildcard, s.PETIndexAccess:
+		return expressions.MakePathAxis(axis, test), nil
+	}
+	return nil, ErrInvalidAxis
+}
+
+func (p pathAxis) Precedence() s.PathPrecedence {
+	return s.PPPostfix
+}
+
+func axisByName(expr s.PathExpression) (s.PathAxisType, bool) {
+	if x, ok := expr.(s.Name); ok && expr.Type() == s.PETName {
+		for _, v := range s.PathAxisTypes() {
+			if v.String() == x.Name() {
+				return v, true
+			}
+		}
+	}
+	return 0, false
+}
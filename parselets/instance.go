@@ -12,7 +12,7 @@ func MakePathInstance() s.PathInfixParselet {
 }
 
 func (p pathInstance) Parse(parser s.PathParser, expr s.PathExpression, token s.PathToken) (s.PathExpression, error) {
-	right, err := parser.ParseExpression()
+	right, err := parser.ParseExpressionBy(s.PPUnion)
 	if err != nil {
 		return nil, err
 	}
@@ -34,6 +34,12 @@ func (p pathGroup) Parse(parser s.PathParser, token s.PathToken) (s.PathExpressi
 	exprs := make([]s.PathExpression, 0)
 
 	for !parser.Match(s.PTTRightParen) {
+		// Only closing the group is expected once the input runs out.
+		if !more(parser) {
+			_, err := parser.ConsumeToken(s.PTTRightParen)
+			return nil, err
+		}
+
 		expr, err := parser.ParseExpression()
 		if err != nil {
 			return nil, err
@@ -51,7 +57,18 @@ func MakePathAttribute() s.PathPrefixParselet {
 }
 
 func (p pathAttribute) Parse(parser s.PathParser, token s.PathToken) (s.PathExpression, error) {
-	return expressions.MakePathAttribute(), nil
+	name, err := parseName(parser, s.PPCall)
+	if err != nil {
+		return nil, err
+	}
+
+	switch name.Type() {
+	case s.PETName, s.PETWildcard:
+	default:
+		return nil, ErrInvalidName
+	}
+
+	return expressions.MakePathNamedAttribute(name), nil
 }
 
 type pathInfixAttribute struct{}
@@ -61,7 +78,7 @@ func MakePathInfixAttribute() s.PathInfixParselet {
 }
 
 func (p pathInfixAttribute) Parse(parser s.PathParser, expr s.PathExpression, token s.PathToken) (s.PathExpression, error) {
-	right, err := parser.ParseExpression()
+	right, err := parseName(parser, s.PPUnion)
 	if err != nil {
 		return nil, err
 	}
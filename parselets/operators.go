@@ -8,9 +8,18 @@ import (
 )
 
 var (
-	ErrInvalidEqualityProperty = errors.New("Invalid Equality Property")
+	ErrInvalidEqualityProperty   = errors.New("Invalid Equality Property")
+	ErrInvalidComparisonProperty = errors.New("Invalid Comparison Property")
 )
 
+func comparisonOperand(expr s.PathExpression) bool {
+	switch expr.Type() {
+	case s.PETAttribute, s.PETMethodCall:
+		return true
+	}
+	return false
+}
+
 type pathEquality struct{}
 
 func MakePathEquality() s.PathInfixParselet {
@@ -18,22 +27,172 @@ func MakePathEquality() s.PathInfixParselet {
 }
 
 func (p pathEquality) Parse(parser s.PathParser, expr s.PathExpression, token s.PathToken) (s.PathExpression, error) {
-	if _, err := parser.ConsumeToken(s.PTTEquality); err != nil {
+	if !comparisonOperand(expr) {
+		return nil, ErrInvalidEqualityProperty
+	}
+
+	right, err := parser.ParseExpressionBy(s.PPComparison)
+	if err != nil {
 		return nil, err
 	}
 
-	if expr.Type() != s.PETName {
+	return expressions.MakePathEquality(expr, right), nil
+}
+
+func (p pathEquality) Precedence() s.PathPrecedence {
+	return s.PPComparison
+}
+
+type pathInequality struct{}
+
+func MakePathInequality() s.PathInfixParselet {
+	return pathInequality{}
+}
+
+func (p pathInequality) Parse(parser s.PathParser, expr s.PathExpression, token s.PathToken) (s.PathExpression, error) {
+	if !comparisonOperand(expr) {
 		return nil, ErrInvalidEqualityProperty
 	}
 
-	right, err := parser.ParseExpression()
+	right, err := parser.ParseExpressionBy(s.PPComparison)
 	if err != nil {
 		return nil, err
 	}
 
-	return expressions.MakePathEquality(expr, right), nil
+	return expressions.MakePathInequality(expr, right), nil
 }
 
-func (p pathEquality) Precedence() s.PathPrecedence {
-	return s.PPPostfix
+func (p pathInequality) Precedence() s.PathPrecedence {
+	return s.PPComparison
+}
+
+type pathLessThan struct{}
+
+func MakePathLessThan() s.PathInfixParselet {
+	return pathLessThan{}
+}
+
+func (p pathLessThan) Parse(parser s.PathParser, expr s.PathExpression, token s.PathToken) (s.PathExpression, error) {
+	fn := expressions.MakePathLessThan
+	if token.Type() == s.PTTBackArrowEquality {
+		fn = expressions.MakePathLessThanOrEqualTo
+	}
+
+	if !comparisonOperand(expr) {
+		return nil, ErrInvalidComparisonProperty
+	}
+
+	right, err := parser.ParseExpressionBy(s.PPComparison)
+	if err != nil {
+		return nil, err
+	}
+
+	return fn(expr, right), nil
+}
+
+func (p pathLessThan) Precedence() s.PathPrecedence {
+	return s.PPComparison
+}
+
+type pathGreaterThan struct{}
+
+func MakePathGreaterThan() s.PathInfixParselet {
+	return pathGreaterThan{}
+}
+
+func (p pathGreaterThan) Parse(parser s.PathParser, expr s.PathExpression, token s.PathToken) (s.PathExpression, error) {
+	fn := expressions.MakePathGreaterThan
+	if token.Type() == s.PTTForwardArrowEquality {
+		fn = expressions.MakePathGreaterThanOrEqualTo
+	}
+
+	if !comparisonOperand(expr) {
+		return nil, ErrInvalidComparisonProperty
+	}
+
+	right, err := parser.ParseExpressionBy(s.PPComparison)
+	if err != nil {
+		return nil, err
+	}
+
+	return fn(expr, right), nil
+}
+
+func (p pathGreaterThan) Precedence() s.PathPrecedence {
+	return s.PPComparison
+}
+
+type pathLogicalAnd struct{}
+
+func MakePathLogicalAnd() s.PathInfixParselet {
+	return pathLogicalAnd{}
+}
+
+func (p pathLogicalAnd) Parse(parser s.PathParser, expr s.PathExpression, token s.PathToken) (s.PathExpression, error) {
+	right, err := parser.ParseExpressionBy(s.PPLogicalAnd)
+	if err != nil {
+		return nil, err
+	}
+
+	return expressions.MakePathLogicalAnd(expr, right), nil
+}
+
+func (p pathLogicalAnd) Precedence() s.PathPrecedence {
+	return s.PPLogicalAnd
+}
+
+type pathLogicalOr struct{}
+
+func MakePathLogicalOr() s.PathInfixParselet {
+	return pathLogicalOr{}
+}
+
+func (p pathLogicalOr) Parse(parser s.PathParser, expr s.PathExpression, token s.PathToken) (s.PathExpression, error) {
+	right, err := parser.ParseExpressionBy(s.PPLogicalOr)
+	if err != nil {
+		return nil, err
+	}
+
+	return expressions.MakePathLogicalOr(expr, right), nil
+}
+
+func (p pathLogicalOr) Precedence() s.PathPrecedence {
+	return s.PPLogicalOr
+}
+
+type pathPipe struct{}
+
+// MakePathPipe handles the union (|) operator.
+func MakePathPipe() s.PathInfixParselet {
+	return pathPipe{}
+}
+
+func (p pathPipe) Parse(parser s.PathParser, expr s.PathExpression, token s.PathToken) (s.PathExpression, error) {
+	right, err := parser.ParseExpressionBy(s.PPUnion)
+	if err != nil {
+		return nil, err
+	}
+
+	return expressions.MakePathUnion(expr, right), nil
+}
+
+func (p pathPipe) Precedence() s.PathPrecedence {
+	return s.PPUnion
+}
+
+type pathLogicalNot struct{}
+
+func MakePathLogicalNot() s.PathPrefixParselet {
+	return pathLogicalNot{}
+}
+
+func (p pathLogicalNot) Parse(parser s.PathParser, token s.PathToken) (s.PathExpression, error) {
+	// Bind looser than the comparisons, so that !@A==1 negates the whole
+	// comparison and not just the attribute.
+	operand, err := parser.ParseExpressionBy(s.PPLogicalAnd)
+	if err != nil {
+		return nil, err
+	}
+
+	return expressions.MakePathLogicalNot(operand), nil
 }
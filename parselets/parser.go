@@ -0,0 +1,32 @@
+package parselets
+
+import (
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+// parseName parses a name, falling back to an expression when the parser
+// can't read keywords as names.
+func parseName(parser s.PathParser, precedence s.PathPrecedence) (s.PathExpression, error) {
+	if x, ok := parser.(s.PathScanner); ok {
+		return x.ParseNameBy(precedence)
+	}
+	return parser.ParseExpressionBy(precedence)
+}
+
+// more reports if there are tokens left, assuming there are when the parser
+// can't tell.
+func more(parser s.PathParser) bool {
+	if x, ok := parser.(s.PathScanner); ok {
+		return x.More()
+	}
+	return true
+}
+
+// check reports if the next token is of the type, which is never known when
+// the parser can't look ahead.
+func check(parser s.PathParser, expected s.PathTokenType) bool {
+	if x, ok := parser.(s.PathScanner); ok {
+		return x.Check(expected)
+	}
+	return false
+}
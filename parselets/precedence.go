@@ -0,0 +1,22 @@
+package parselets
+
+import (
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+type pathPrecedence struct {
+	s.PathInfixParselet
+	precedence s.PathPrecedence
+}
+
+// MakePathPrecedence changes the precedence of the infix parselet.
+func MakePathPrecedence(parselet s.PathInfixParselet, precedence s.PathPrecedence) s.PathInfixParselet {
+	return pathPrecedence{
+		PathInfixParselet: parselet,
+		precedence:        precedence,
+	}
+}
+
+func (p pathPrecedence) Precedence() s.PathPrecedence {
+	return p.precedence
+}
@@ -2,30 +2,33 @@ package cilli
 
 import (
 	"errors"
+	"sort"
 
+	"github.com/SimonRichardson/cilli/expressions"
 	"github.com/SimonRichardson/cilli/parselets"
 	s "github.com/SimonRichardson/cilli/selectors"
 )
 
 var (
-	ErrParsePrefixError = errors.New("Parse Prefix Error")
-	ErrParseInfixError  = errors.New("Parse Infix Error")
-	ErrBufferUnderflow  = errors.New("Buffer Underflow")
-	ErrBufferOverflow   = errors.New("Buffer Overflow")
-	ErrUnexpectedToken  = errors.New("Unexpected Token")
+	ErrParsePrefixError     = errors.New("Parse Prefix Error")
+	ErrParseInfixError      = errors.New("Parse Infix Error")
+	ErrBufferUnderflow      = errors.New("Buffer Underflow")
+	ErrBufferOverflow       = errors.New("Buffer Overflow")
+	ErrUnexpectedToken      = errors.New("Unexpected Token")
+	ErrUnexpectedEndOfInput = errors.New("Unexpected End Of Input")
 )
 
-type pathParser struct {
-	tokens s.PathLexerIterator
-	prefix map[s.PathTokenType]s.PathPrefixParselet
-	infix  map[s.PathTokenType]s.PathInfixParselet
-	stream []s.PathToken
+// ParserConfig holds the parselets used by the parser, keyed by the token
+// they start from. It's immutable, every method returns a new config.
+type ParserConfig struct {
+	Prefix map[s.PathTokenType]s.PathPrefixParselet
+	Infix  map[s.PathTokenType]s.PathInfixParselet
 }
 
-func NewPathParser(iter s.PathLexerIterator) s.PathParser {
-	return &pathParser{
-		tokens: iter,
-		prefix: map[s.PathTokenType]s.PathPrefixParselet{
+// DefaultParserConfig returns the config with all the built-in parselets.
+func DefaultParserConfig() ParserConfig {
+	return ParserConfig{
+		Prefix: map[s.PathTokenType]s.PathPrefixParselet{
 			s.PTTName:         parselets.MakePathName(),
 			s.PTTNumber:       parselets.MakePathNumber(),
 			s.PTTString:       parselets.MakePathString(),
@@ -33,36 +36,204 @@ func NewPathParser(iter s.PathLexerIterator) s.PathParser {
 			s.PTTForwardSlash: parselets.MakePathDescendants(),
 			s.PTTLeftParen:    parselets.MakePathGroup(),
 			s.PTTAttribute:    parselets.MakePathAttribute(),
+			s.PTTBang:         parselets.MakePathLogicalNot(),
+			s.PTTNot:          parselets.MakePathLogicalNot(),
+			s.PTTDot:          parselets.MakePathSelf(),
+			s.PTTDoubleDot:    parselets.MakePathSelf(),
+			// The remaining keywords are still names where an operator isn't
+			// expected, so that /event/or selects the or element.
+			s.PTTAnd: parselets.MakePathName(),
+			s.PTTOr:  parselets.MakePathName(),
 		},
-		infix: map[s.PathTokenType]s.PathInfixParselet{
-			s.PTTDot:          parselets.MakePathInstance(),
-			s.PTTForwardSlash: parselets.MakePathNameDescendants(),
-			s.PTTLeftSquare:   parselets.MakePathIndexAccess(),
-			s.PTTAttribute:    parselets.MakePathInfixAttribute(),
-			s.PTTEquality:     parselets.MakePathEquality(),
+		Infix: map[s.PathTokenType]s.PathInfixParselet{
+			s.PTTDot:                  parselets.MakePathInstance(),
+			s.PTTForwardSlash:         parselets.MakePathNameDescendants(),
+			s.PTTLeftSquare:           parselets.MakePathIndexAccess(),
+			s.PTTLeftParen:            parselets.MakePathMethodCall(),
+			s.PTTAttribute:            parselets.MakePathInfixAttribute(),
+			s.PTTDoubleEquality:       parselets.MakePathEquality(),
+			s.PTTBangEquality:         parselets.MakePathInequality(),
+			s.PTTBackArrow:            parselets.MakePathLessThan(),
+			s.PTTBackArrowEquality:    parselets.MakePathLessThan(),
+			s.PTTForwardArrow:         parselets.MakePathGreaterThan(),
+			s.PTTForwardArrowEquality: parselets.MakePathGreaterThan(),
+			s.PTTDoubleAmpersand:      parselets.MakePathLogicalAnd(),
+			s.PTTAnd:                  parselets.MakePathLogicalAnd(),
+			s.PTTDoublePipe:           parselets.MakePathLogicalOr(),
+			s.PTTOr:                   parselets.MakePathLogicalOr(),
+			s.PTTPipe:                 parselets.MakePathPipe(),
+			s.PTTDoubleColon:          parselets.MakePathAxis(),
 		},
+	}
+}
+
+// WithPrefix registers the prefix parselet, overriding any existing one.
+func (c ParserConfig) WithPrefix(tokenType s.PathTokenType, parselet s.PathPrefixParselet) ParserConfig {
+	res := c.copy()
+	res.Prefix[tokenType] = parselet
+	return res
+}
+
+// WithInfix registers the infix parselet, overriding any existing one. Use
+// parselets.MakePathPrecedence to change the precedence of an existing one.
+func (c ParserConfig) WithInfix(tokenType s.PathTokenType, parselet s.PathInfixParselet) ParserConfig {
+	res := c.copy()
+	res.Infix[tokenType] = parselet
+	return res
+}
+
+func (c ParserConfig) WithoutPrefix(tokenType s.PathTokenType) ParserConfig {
+	res := c.copy()
+	delete(res.Prefix, tokenType)
+	return res
+}
+
+func (c ParserConfig) WithoutInfix(tokenType s.PathTokenType) ParserConfig {
+	res := c.copy()
+	delete(res.Infix, tokenType)
+	return res
+}
+
+func (c ParserConfig) copy() ParserConfig {
+	res := ParserConfig{
+		Prefix: make(map[s.PathTokenType]s.PathPrefixParselet, len(c.Prefix)),
+		Infix:  make(map[s.PathTokenType]s.PathInfixParselet, len(c.Infix)),
+	}
+	for k, v := range c.Prefix {
+		res.Prefix[k] = v
+	}
+	for k, v := range c.Infix {
+		res.Infix[k] = v
+	}
+	return res
+}
+
+type pathParser struct {
+	tokens s.PathLexerIterator
+	source string
+	last   s.PathToken
+	prefix map[s.PathTokenType]s.PathPrefixParselet
+	infix  map[s.PathTokenType]s.PathInfixParselet
+	stream []s.PathToken
+
+	recovering  bool
+	diagnostics []*ParseError
+}
+
+func NewPathParser(iter s.PathLexerIterator) s.PathParser {
+	return NewPathParserWith(iter, DefaultParserConfig())
+}
+
+// NewPathParserWith creates a parser using the parselets of the config.
+func NewPathParserWith(iter s.PathLexerIterator, config ParserConfig) s.PathParser {
+	var source string
+	if x, ok := iter.(interface {
+		Source() string
+	}); ok {
+		source = x.Source()
+	}
+
+	// Copy so that changes to the config don't affect the parser.
+	config = config.copy()
+
+	return &pathParser{
+		source: source,
+		tokens: iter,
+		prefix: config.Prefix,
+		infix:  config.Infix,
 		stream: []s.PathToken{},
 	}
 }
 
+// RecoveringPathParser parses a path without stopping at the first error.
+// Anything that fails to parse is replaced by an error node, after skipping
+// ahead to the next /, ) or ], so that every error is found in one pass.
+type RecoveringPathParser interface {
+	s.PathParser
+
+	// Recover parses the whole source, returning the partial expression along
+	// with every error that was found.
+	Recover() (s.PathExpression, []*ParseError)
+}
+
+func NewRecoveringPathParser(iter s.PathLexerIterator) RecoveringPathParser {
+	return NewRecoveringPathParserWith(iter, DefaultParserConfig())
+}
+
+func NewRecoveringPathParserWith(iter s.PathLexerIterator, config ParserConfig) RecoveringPathParser {
+	res := NewPathParserWith(iter, config).(*pathParser)
+	res.recovering = true
+	return res
+}
+
+func (p *pathParser) Recover() (s.PathExpression, []*ParseError) {
+	expression, err := p.ParseExpression()
+	if err != nil {
+		err = p.errorAt(err, p.last, p.last, nil)
+		p.report(err)
+		return expressions.MakePathError(err, nil), p.diagnostics
+	}
+
+	// Anything left over couldn't be parsed as part of the expression, so
+	// report it and carry on with whatever follows.
+	for {
+		token, err := p.advance(0)
+		if err != nil {
+			break
+		}
+		p.report(p.errorAt(ErrUnexpectedToken, token, token, nil))
+		if _, err := p.Consume(); err != nil {
+			break
+		}
+
+		if _, err := p.advance(0); err == nil {
+			rest, err := p.ParseExpression()
+			if err != nil {
+				p.report(p.errorAt(err, p.last, p.last, nil))
+				break
+			}
+			expression = expressions.MakePathBranch(expression, rest)
+		}
+	}
+
+	return expression, p.diagnostics
+}
+
 func (p *pathParser) ParseExpression() (s.PathExpression, error) {
 	return p.ParseExpressionBy(0)
 }
 
 func (p *pathParser) ParseExpressionBy(precedence s.PathPrecedence) (s.PathExpression, error) {
+	return p.parse(precedence, false)
+}
+
+func (p *pathParser) ParseNameBy(precedence s.PathPrecedence) (s.PathExpression, error) {
+	return p.parse(precedence, true)
+}
+
+func (p *pathParser) parse(precedence s.PathPrecedence, name bool) (s.PathExpression, error) {
+	// Running out of tokens is never recovered from here, as nothing more can
+	// be parsed, instead it's left to the enclosing parselet.
 	token, err := p.Consume()
 	if err != nil {
-		return nil, err
+		return nil, p.errorAt(err, p.last, p.last, p.prefixTypes())
 	}
 
 	// fmt.Println("Prefix", token)
 
+	first := token
+
 	prefix, ok := p.prefix[token.Type()]
-	if !ok {
-		return nil, ErrParsePrefixError
+	if name && token.Type().Keyword() {
+		prefix, ok = parselets.MakePathName(), true
 	}
 
-	expression, err := prefix.Parse(p, token)
+	var expression s.PathExpression
+	if !ok {
+		expression, err = p.fail(p.errorAt(ErrParsePrefixError, token, token, p.prefixTypes()), nil)
+	} else if expression, err = prefix.Parse(p, token); err != nil {
+		expression, err = p.fail(p.errorAt(err, token, p.last, nil), nil)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -83,13 +254,17 @@ func (p *pathParser) ParseExpressionBy(precedence s.PathPrecedence) (s.PathExpre
 
 			infix, ok := p.infix[token.Type()]
 			if !ok {
-				return nil, ErrParseInfixError
+				return nil, p.errorAt(ErrParseInfixError, token, token, nil)
 			}
 
-			expression, err = infix.Parse(p, expression, token)
+			// Infix errors span the left operand as well.
+			res, err := infix.Parse(p, expression, token)
 			if err != nil {
-				return nil, err
+				if res, err = p.fail(p.errorAt(err, first, p.last, nil), expression); err != nil {
+					return nil, err
+				}
 			}
+			expression = res
 			continue
 		}
 		break
@@ -98,6 +273,62 @@ func (p *pathParser) ParseExpressionBy(precedence s.PathPrecedence) (s.PathExpre
 	return expression, nil
 }
 
+// fail returns the error, unless recovering, in which case the error is
+// reported and the tokens are skipped up to the next /, ) or ]. An error node
+// holding the partial expression is then returned in its place.
+func (p *pathParser) fail(err error, partial s.PathExpression) (s.PathExpression, error) {
+	if !p.recovering {
+		return nil, err
+	}
+	p.report(err)
+
+loop:
+	for {
+		token, err := p.advance(0)
+		if err != nil {
+			break
+		}
+
+		switch token.Type() {
+		case s.PTTForwardSlash, s.PTTRightParen, s.PTTRightSquare:
+			break loop
+		}
+		if _, err := p.Consume(); err != nil {
+			break
+		}
+	}
+	return expressions.MakePathError(err, partial), nil
+}
+
+// report adds the error to the diagnostics, unless the same error has already
+// been reported at the same place.
+func (p *pathParser) report(err error) {
+	x, ok := err.(*ParseError)
+	if !ok {
+		return
+	}
+	for _, v := range p.diagnostics {
+		if v.Err == x.Err && v.Start == x.Start {
+			return
+		}
+	}
+	p.diagnostics = append(p.diagnostics, x)
+}
+
+func (p *pathParser) More() bool {
+	_, err := p.advance(0)
+	return err == nil
+}
+
+func (p *pathParser) Check(expected s.PathTokenType) bool {
+	token, err := p.advance(0)
+	if err != nil {
+		return false
+	}
+
+	return token.Type() == expected
+}
+
 func (p *pathParser) Match(expected s.PathTokenType) bool {
 	token, err := p.advance(0)
 	if err != nil {
@@ -125,21 +356,62 @@ func (p *pathParser) Consume() (s.PathToken, error) {
 
 	res := p.stream[0]
 	p.stream = p.stream[1:]
+	p.last = res
 	return res, nil
 }
 
 func (p *pathParser) ConsumeToken(expected s.PathTokenType) (s.PathToken, error) {
 	token, err := p.advance(0)
 	if err != nil {
-		return s.PathToken{}, err
+		return s.PathToken{}, p.errorAt(err, p.last, p.last, []s.PathTokenType{expected})
 	}
 	if token.Type() != expected {
-		return s.PathToken{}, ErrUnexpectedToken
+		return s.PathToken{}, p.errorAt(ErrUnexpectedToken, token, token, []s.PathTokenType{expected})
 	}
 
 	return p.Consume()
 }
 
+// errorAt wraps the error as a ParseError spanning the tokens. Running out of
+// tokens is reported as ErrUnexpectedEndOfInput at the end of the last token.
+// Errors that are already a ParseError are returned as is, so the innermost
+// location is kept.
+func (p *pathParser) errorAt(err error, start, end s.PathToken, expected []s.PathTokenType) error {
+	if _, ok := err.(*ParseError); ok {
+		return err
+	}
+
+	from, to := start.Start(), end.End()
+	if err == ErrBufferOverflow {
+		err, from = ErrUnexpectedEndOfInput, to
+	}
+	if from.Line == 0 {
+		from = s.PathPosition{Line: 1, Column: 1}
+	}
+	if to.Offset < from.Offset {
+		to = from
+	}
+
+	return &ParseError{
+		Err:      err,
+		Source:   p.source,
+		Start:    from,
+		End:      to,
+		Expected: expected,
+	}
+}
+
+func (p *pathParser) prefixTypes() []s.PathTokenType {
+	res := make([]s.PathTokenType, 0, len(p.prefix))
+	for k := range p.prefix {
+		res = append(res, k)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i] < res[j]
+	})
+	return res
+}
+
 func (p *pathParser) advance(distance int) (s.PathToken, error) {
 	for {
 		if distance >= len(p.stream) {
@@ -149,6 +421,12 @@ func (p *pathParser) advance(distance int) (s.PathToken, error) {
 
 			next, err := p.tokens.Next()
 			if err != nil {
+				// Skip anything the lexer can't make sense of, so that the
+				// tokens following it can still be parsed.
+				if x, ok := err.(*ParseError); ok && p.recovering {
+					p.report(x)
+					continue
+				}
 				return s.PathToken{}, err
 			}
 			p.stream = append(p.stream, next)
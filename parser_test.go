@@ -1,11 +1,14 @@
 package cilli
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
 	"testing"
 	"testing/quick"
 
 	"github.com/SimonRichardson/cilli/expressions"
+	"github.com/SimonRichardson/cilli/parselets"
 	s "github.com/SimonRichardson/cilli/selectors"
 )
 
@@ -23,7 +26,7 @@ func Test_PathParserForString(t *testing.T) {
 			return res
 		}
 		g = func(a string) s.PathExpression {
-			return expressions.MakePathString(fmt.Sprintf("%q", a))
+			return expressions.MakePathString(a)
 		}
 	)
 
@@ -94,7 +97,7 @@ func Test_PathParserWithTypesForOneForwardSlash(t *testing.T) {
 		g = func(a string) s.PathExpression {
 			return expressions.MakePathDescendants(
 				s.PDTContext,
-				expressions.MakePathString(fmt.Sprintf("%q", a)),
+				expressions.MakePathName(a),
 			)
 		}
 	)
@@ -120,7 +123,7 @@ func Test_PathParserWithTypesForTwoForwardSlashes(t *testing.T) {
 		g = func(a string) s.PathExpression {
 			return expressions.MakePathDescendants(
 				s.PDTAll,
-				expressions.MakePathString(fmt.Sprintf("%q", a)),
+				expressions.MakePathName(a),
 			)
 		}
 	)
@@ -204,10 +207,9 @@ func Test_PathParserWithTypesForNamedGroupWithEquality(t *testing.T) {
 			return expressions.MakePathInstance(
 				expressions.MakePathName(a.String()),
 				expressions.MakePathGroup([]s.PathExpression{
-					expressions.MakePathAttribute(),
 					expressions.MakePathEquality(
-						expressions.MakePathName("Name"),
-						expressions.MakePathString(fmt.Sprintf("%q", a.String())),
+						expressions.MakePathNamedAttribute(expressions.MakePathName("Name")),
+						expressions.MakePathString(a.String()),
 					),
 				}),
 			)
@@ -240,19 +242,17 @@ func Test_PathParserWithTypesForDoubleGroupWithEquality(t *testing.T) {
 					expressions.MakePathName(a.String()),
 					expressions.MakePathBranch(
 						expressions.MakePathGroup([]s.PathExpression{
-							expressions.MakePathAttribute(),
 							expressions.MakePathEquality(
-								expressions.MakePathName("Name"),
-								expressions.MakePathString(fmt.Sprintf("%q", a.String())),
+								expressions.MakePathNamedAttribute(expressions.MakePathName("Name")),
+								expressions.MakePathString(a.String()),
 							),
 						}),
 						expressions.MakePathInstance(
 							expressions.MakePathName(a.String()),
 							expressions.MakePathGroup([]s.PathExpression{
-								expressions.MakePathAttribute(),
 								expressions.MakePathEquality(
-									expressions.MakePathName("Name"),
-									expressions.MakePathString(fmt.Sprintf("%q", a.String())),
+									expressions.MakePathNamedAttribute(expressions.MakePathName("Name")),
+									expressions.MakePathString(a.String()),
 								),
 							}),
 						),
@@ -266,3 +266,545 @@ func Test_PathParserWithTypesForDoubleGroupWithEquality(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func Test_PathParserWithTypesForNamedGroupWithComparisons(t *testing.T) {
+	for dsl, fn := range map[string]func(s.PathExpression, s.PathExpression) s.PathExpression{
+		"node.(@Priority!=3)": expressions.MakePathInequality,
+		"node.(@Priority<3)":  expressions.MakePathLessThan,
+		"node.(@Priority<=3)": expressions.MakePathLessThanOrEqualTo,
+		"node.(@Priority>3)":  expressions.MakePathGreaterThan,
+		"node.(@Priority>=3)": expressions.MakePathGreaterThanOrEqualTo,
+	} {
+		var (
+			lex      = NewPathLexer(dsl).With(s.PathTokenTypes())
+			parser   = NewPathParser(lex.Iter())
+			res, err = parser.ParseExpression()
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := expressions.MakePathInstance(
+			expressions.MakePathName("node"),
+			expressions.MakePathGroup([]s.PathExpression{
+				fn(
+					expressions.MakePathNamedAttribute(expressions.MakePathName("Priority")),
+					expressions.MakePathNumber(3),
+				),
+			}),
+		)
+		if !reflect.DeepEqual(res, expected) {
+			t.Errorf("%s: expected %v, got %v", dsl, expected, res)
+		}
+	}
+}
+
+func Test_PathParserWithTypesForGroupWithLogicalPrecedence(t *testing.T) {
+	var (
+		attribute = func(name string, value float64) s.PathExpression {
+			return expressions.MakePathEquality(
+				expressions.MakePathNamedAttribute(expressions.MakePathName(name)),
+				expressions.MakePathNumber(value),
+			)
+		}
+		lex      = NewPathLexer("node.(@A==1 || !@B==2 && (@C==3))").With(s.PathTokenTypes())
+		parser   = NewPathParser(lex.Iter())
+		res, err = parser.ParseExpression()
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := expressions.MakePathInstance(
+		expressions.MakePathName("node"),
+		expressions.MakePathGroup([]s.PathExpression{
+			expressions.MakePathLogicalOr(
+				attribute("A", 1),
+				expressions.MakePathLogicalAnd(
+					expressions.MakePathLogicalNot(attribute("B", 2)),
+					expressions.MakePathGroup([]s.PathExpression{
+						attribute("C", 3),
+					}),
+				),
+			),
+		}),
+	)
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected %v, got %v", expected, res)
+	}
+}
+
+func Test_PathParserWithTypesForGroupWithKeywords(t *testing.T) {
+	var (
+		attribute = func(name string, value float64) s.PathExpression {
+			return expressions.MakePathEquality(
+				expressions.MakePathNamedAttribute(expressions.MakePathName(name)),
+				expressions.MakePathNumber(value),
+			)
+		}
+		lex      = NewPathLexer("or.(@A==1 or not @B==2 and @C==3)").With(s.PathTokenTypes())
+		parser   = NewPathParser(lex.Iter())
+		res, err = parser.ParseExpression()
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := expressions.MakePathInstance(
+		expressions.MakePathName("or"),
+		expressions.MakePathGroup([]s.PathExpression{
+			expressions.MakePathLogicalOr(
+				attribute("A", 1),
+				expressions.MakePathLogicalAnd(
+					expressions.MakePathLogicalNot(attribute("B", 2)),
+					attribute("C", 3),
+				),
+			),
+		}),
+	)
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected %v, got %v", expected, res)
+	}
+
+	// Keywords are still names in steps and attributes.
+	var (
+		not  = expressions.MakePathName("not")
+		name = expressions.MakePathName("a")
+	)
+	for dsl, expected := range map[string]s.PathExpression{
+		"/not": expressions.MakePathDescendants(s.PDTContext, not),
+		"/a/not[0]": expressions.MakePathDescendants(s.PDTContext,
+			expressions.MakePathNameDescendants(name, expressions.MakePathIndexAccess(not, expressions.MakePathNumber(0))),
+		),
+		"/a.(@not==1)": expressions.MakePathDescendants(s.PDTContext,
+			expressions.MakePathInstance(name, expressions.MakePathGroup([]s.PathExpression{attribute("not", 1)})),
+		),
+		"/a.(not @and==1)": expressions.MakePathDescendants(s.PDTContext,
+			expressions.MakePathInstance(name, expressions.MakePathGroup([]s.PathExpression{
+				expressions.MakePathLogicalNot(attribute("and", 1)),
+			})),
+		),
+		"child::not": expressions.MakePathAxis(s.PAXChild, not),
+	} {
+		lex := NewPathLexer(dsl).With(s.PathTokenTypes())
+		res, err := NewPathParser(lex.Iter()).ParseExpression()
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+		if !reflect.DeepEqual(res, expected) {
+			t.Errorf("%s: expected %v, got %v", dsl, expected, res)
+		}
+	}
+}
+
+func Test_PathParserWithTypesForNamedSliceAccess(t *testing.T) {
+	var (
+		number = func(v float64) s.PathExpression {
+			return expressions.MakePathNumber(v)
+		}
+		name = expressions.MakePathName("node")
+	)
+
+	for dsl, expected := range map[string]s.PathExpression{
+		"node[-1]":    expressions.MakePathIndexAccess(name, number(-1)),
+		"node[1:5]":   expressions.MakePathIndexAccess(name, expressions.MakePathSlice(number(1), number(5), nil)),
+		"node[::2]":   expressions.MakePathIndexAccess(name, expressions.MakePathSlice(nil, nil, number(2))),
+		"node[1::2]":  expressions.MakePathIndexAccess(name, expressions.MakePathSlice(number(1), nil, number(2))),
+		"node[:-1:]":  expressions.MakePathIndexAccess(name, expressions.MakePathSlice(nil, number(-1), nil)),
+		"node[0,2,4]": expressions.MakePathIndexAccess(name, expressions.MakePathIndexList([]s.PathExpression{number(0), number(2), number(4)})),
+	} {
+		var (
+			lex      = NewPathLexer(dsl).With(s.PathTokenTypes())
+			parser   = NewPathParser(lex.Iter())
+			res, err = parser.ParseExpression()
+		)
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+
+		if !reflect.DeepEqual(res, expected) {
+			t.Errorf("%s: expected %v, got %v", dsl, expected, res)
+		}
+	}
+}
+
+// basicParser only implements s.PathParser, hiding the s.PathScanner methods
+// of the parser it wraps.
+type basicParser struct {
+	s.PathParser
+}
+
+func Test_PathParserWithoutScanner(t *testing.T) {
+	var (
+		number = func(v float64) s.PathExpression {
+			return expressions.MakePathNumber(v)
+		}
+		name  = expressions.MakePathName("node")
+		token = s.MakePathToken(s.PTTLeftSquare, "[")
+		parse = func(dsl string) (s.PathExpression, error) {
+			lex := NewPathLexer(dsl).With(s.PathTokenTypes())
+			return parselets.MakePathIndexAccess().Parse(basicParser{NewPathParser(lex.Iter())}, name, token)
+		}
+	)
+
+	for dsl, expected := range map[string]s.PathExpression{
+		"1]":     expressions.MakePathIndexAccess(name, number(1)),
+		"1:5:2]": expressions.MakePathIndexAccess(name, expressions.MakePathSlice(number(1), number(5), number(2))),
+		"0,2,4]": expressions.MakePathIndexAccess(name, expressions.MakePathIndexList([]s.PathExpression{number(0), number(2), number(4)})),
+	} {
+		res, err := parse(dsl)
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+		if !reflect.DeepEqual(res, expected) {
+			t.Errorf("%s: expected %v, got %v", dsl, expected, res)
+		}
+	}
+
+	// Leaving out an index needs the parser to look ahead.
+	if _, err := parse(":5]"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func Test_PathParserWithTypesForUnion(t *testing.T) {
+	var (
+		lex      = NewPathLexer("/event/colour | /event/shape.(@A==1 || @B==2)").With(s.PathTokenTypes())
+		parser   = NewPathParser(lex.Iter())
+		res, err = parser.ParseExpression()
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := expressions.MakePathUnion(
+		expressions.MakePathDescendants(
+			s.PDTContext,
+			expressions.MakePathNameDescendants(
+				expressions.MakePathName("event"),
+				expressions.MakePathName("colour"),
+			),
+		),
+		expressions.MakePathDescendants(
+			s.PDTContext,
+			expressions.MakePathNameDescendants(
+				expressions.MakePathName("event"),
+				expressions.MakePathInstance(
+					expressions.MakePathName("shape"),
+					expressions.MakePathGroup([]s.PathExpression{
+						expressions.MakePathLogicalOr(
+							expressions.MakePathEquality(
+								expressions.MakePathNamedAttribute(expressions.MakePathName("A")),
+								expressions.MakePathNumber(1),
+							),
+							expressions.MakePathEquality(
+								expressions.MakePathNamedAttribute(expressions.MakePathName("B")),
+								expressions.MakePathNumber(2),
+							),
+						),
+					}),
+				),
+			),
+		),
+	)
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected %v, got %v", expected, res)
+	}
+}
+
+func Test_PathParserWithTypesForAxes(t *testing.T) {
+	var (
+		name = func(v string) s.PathExpression {
+			return expressions.MakePathName(v)
+		}
+		wildcard = expressions.MakePathWildcard()
+	)
+
+	for dsl, expected := range map[string]s.PathExpression{
+		"..": expressions.MakePathAxis(s.PAXParent, wildcard),
+		".":  expressions.MakePathAxis(s.PAXSelf, wildcard),
+		"node/..": expressions.MakePathNameDescendants(
+			name("node"),
+			expressions.MakePathAxis(s.PAXParent, wildcard),
+		),
+		"ancestor::node": expressions.MakePathAxis(s.PAXAncestor, name("node")),
+		"following-sibling::*[0]": expressions.MakePathAxis(
+			s.PAXFollowingSibling,
+			expressions.MakePathIndexAccess(wildcard, expressions.MakePathNumber(0)),
+		),
+		"preceding-sibling::node/child": expressions.MakePathBranch(
+			expressions.MakePathAxis(s.PAXPrecedingSibling, name("node")),
+			name("child"),
+		),
+	} {
+		var (
+			lex      = NewPathLexer(dsl).With(s.PathTokenTypes())
+			parser   = NewPathParser(lex.Iter())
+			res, err = parser.ParseExpression()
+		)
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+
+		if !reflect.DeepEqual(res, expected) {
+			t.Errorf("%s: expected %v, got %v", dsl, expected, res)
+		}
+	}
+}
+
+func Test_PathParserWithParseErrors(t *testing.T) {
+	for _, test := range []struct {
+		dsl      string
+		err      error
+		start    s.PathPosition
+		expected []s.PathTokenType
+		render   string
+	}{
+		{
+			dsl:    "/event/$colour",
+			err:    ErrInvalidCharacter,
+			start:  s.PathPosition{Offset: 7, Line: 1, Column: 8},
+			render: "/event/$colour\n       ^",
+		},
+		{
+			dsl:      "/event[0",
+			err:      ErrUnexpectedEndOfInput,
+			start:    s.PathPosition{Offset: 8, Line: 1, Column: 9},
+			expected: []s.PathTokenType{s.PTTRightSquare},
+			render:   "/event[0\n        ^",
+		},
+		{
+			dsl:      "/event.(@A==1",
+			err:      ErrUnexpectedEndOfInput,
+			start:    s.PathPosition{Offset: 13, Line: 1, Column: 14},
+			expected: []s.PathTokenType{s.PTTRightParen},
+			render:   "/event.(@A==1\n             ^",
+		},
+		{
+			dsl:      "/event.(contains(@A,",
+			err:      ErrUnexpectedEndOfInput,
+			start:    s.PathPosition{Offset: 20, Line: 1, Column: 21},
+			expected: []s.PathTokenType{s.PTTRightParen},
+			render:   "/event.(contains(@A,\n                    ^",
+		},
+		{
+			dsl:      "/event[0)",
+			err:      ErrUnexpectedToken,
+			start:    s.PathPosition{Offset: 8, Line: 1, Column: 9},
+			expected: []s.PathTokenType{s.PTTRightSquare},
+			render:   "/event[0)\n        ^",
+		},
+		{
+			dsl:    "/event\n\t/colour/sideways::shape",
+			err:    parselets.ErrInvalidAxis,
+			start:  s.PathPosition{Offset: 16, Line: 2, Column: 10},
+			render: "\t/colour/sideways::shape\n\t        ^^^^^^^^^^",
+		},
+	} {
+		var (
+			lex    = NewPathLexer(test.dsl).With(s.PathTokenTypes())
+			parser = NewPathParser(lex.Iter())
+			_, err = parser.ParseExpression()
+		)
+
+		perr, ok := err.(*ParseError)
+		if !ok {
+			t.Fatalf("%s: expected a parse error, got %v", test.dsl, err)
+		}
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v, got %v", test.dsl, test.err, perr.Err)
+		}
+		if perr.Start != test.start {
+			t.Errorf("%s: expected start %v, got %v", test.dsl, test.start, perr.Start)
+		}
+		if test.expected != nil && !reflect.DeepEqual(perr.Expected, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.dsl, test.expected, perr.Expected)
+		}
+		if render := perr.Render(); render != test.render {
+			t.Errorf("%s: expected\n%s\ngot\n%s", test.dsl, test.render, render)
+		}
+	}
+}
+
+func Test_ParseErrorRenderOutsideOfSource(t *testing.T) {
+	for _, test := range []struct {
+		err      *ParseError
+		expected string
+	}{
+		{&ParseError{Err: ErrUnexpectedEndOfInput}, "\n^"},
+		{&ParseError{Err: ErrUnexpectedEndOfInput, Start: s.PathPosition{Offset: 3}, End: s.PathPosition{Offset: 5}}, "\n^"},
+		{&ParseError{Err: ErrUnexpectedToken, Source: "/a", Start: s.PathPosition{Offset: 9}}, "/a\n  ^"},
+	} {
+		if render := test.err.Render(); render != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, render)
+		}
+	}
+}
+
+func Test_PathParserWithRecovery(t *testing.T) {
+	for _, test := range []struct {
+		dsl      string
+		expected []error
+		starts   []int
+	}{
+		{"/event/colour", nil, nil},
+		{"/event[0/colour.(@A=>1)/shape.(@B==2", []error{ErrUnexpectedToken, ErrParsePrefixError, ErrUnexpectedEndOfInput}, []int{8, 19, 36}},
+		{"/event/)/colour", []error{ErrParsePrefixError}, []int{7}},
+		{"/event/$colour/%shape", []error{ErrInvalidCharacter, ErrInvalidCharacter}, []int{7, 15}},
+		{"/event]/colour", []error{ErrUnexpectedToken}, []int{6}},
+		{"#", []error{ErrInvalidCharacter, ErrUnexpectedEndOfInput}, []int{0, 0}},
+		{`/a/b/c.(@x=="unterminated)`, []error{ErrUnterminatedString, ErrUnexpectedEndOfInput}, []int{12, 12}},
+	} {
+		var (
+			lex               = NewPathLexer(test.dsl).With(s.PathTokenTypes())
+			parser            = NewRecoveringPathParser(lex.Iter())
+			expr, diagnostics = parser.Recover()
+		)
+		if expr == nil {
+			t.Errorf("%s: expected a partial expression", test.dsl)
+		}
+
+		if len(diagnostics) != len(test.expected) {
+			t.Fatalf("%s: expected %d diagnostics, got %v", test.dsl, len(test.expected), diagnostics)
+		}
+		for k, v := range diagnostics {
+			if v.Err != test.expected[k] || v.Start.Offset != test.starts[k] {
+				t.Errorf("%s: expected %v at %d, got %v at %d", test.dsl, test.expected[k], test.starts[k], v.Err, v.Start.Offset)
+			}
+		}
+	}
+
+	// The partial expression keeps everything around the error.
+	lex := NewPathLexer("/event[0/colour").With(s.PathTokenTypes())
+	expr, _ := NewRecoveringPathParser(lex.Iter()).Recover()
+
+	x, ok := expr.(s.Descendants)
+	if !ok {
+		t.Fatalf("expected descendants, got %v", expr)
+	}
+	y, ok := x.Descendants().(s.Branch)
+	if !ok || y.Left().Type() != s.PETError || y.Right().Type() != s.PETName {
+		t.Fatalf("expected an error then colour, got %v", x.Descendants())
+	}
+	if z, ok := y.Left().(s.Unary); !ok || z.Operand() != expressions.MakePathName("event") {
+		t.Errorf("expected the error to hold event, got %v", y.Left())
+	}
+}
+
+const (
+	pttHash s.PathTokenType      = 100
+	petHash s.PathExpressionType = 100
+)
+
+// hashType is a custom expression, #name selects the children whose name
+// starts with name.
+type hashType struct {
+	prefix string
+}
+
+func (h hashType) Type() s.PathExpressionType {
+	return petHash
+}
+
+type pathHash struct{}
+
+func (p pathHash) Parse(parser s.PathParser, token s.PathToken) (s.PathExpression, error) {
+	name, err := parser.Consume()
+	if err != nil {
+		return nil, err
+	}
+	if name.Type() != s.PTTName {
+		return nil, ErrUnexpectedToken
+	}
+	return hashType{name.Val()}, nil
+}
+
+func Test_PathParserWithConfig(t *testing.T) {
+	lex := NewPathLexer("/event/#col").With(s.PathTokenTypes()).WithRune('#', pttHash)
+
+	if _, err := NewPathParser(lex.Iter()).ParseExpression(); !errors.Is(err, ErrParsePrefixError) {
+		t.Errorf("expected %v, got %v", ErrParsePrefixError, err)
+	}
+
+	var (
+		config    = DefaultParserConfig().WithPrefix(pttHash, pathHash{})
+		expr, err = NewPathParserWith(lex.Iter(), config).ParseExpression()
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x, ok := expr.(s.Descendants); !ok || x.Descendants().(s.Branch).Right() != (hashType{"col"}) {
+		t.Errorf("expected #col, got %v", expr)
+	}
+
+	// Changing the precedence of the union binds it tighter than a step.
+	for _, test := range []struct {
+		config   ParserConfig
+		expected s.PathExpressionType
+	}{
+		{DefaultParserConfig(), s.PETUnion},
+		{DefaultParserConfig().WithInfix(s.PTTPipe, parselets.MakePathPrecedence(parselets.MakePathPipe(), s.PPCall+1)), s.PETNameDescendants},
+	} {
+		lex := NewPathLexer("event/colour|shape").With(s.PathTokenTypes())
+		expr, err := NewPathParserWith(lex.Iter(), test.config).ParseExpression()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expr.Type() != test.expected {
+			t.Errorf("expected %v, got %v", test.expected, expr.Type())
+		}
+	}
+
+	// Removing a parselet leaves its token unexpected.
+	lex = NewPathLexer("event|shape").With(s.PathTokenTypes())
+	_, diagnostics := NewRecoveringPathParserWith(lex.Iter(), DefaultParserConfig().WithoutInfix(s.PTTPipe)).Recover()
+	if len(diagnostics) != 1 || diagnostics[0].Err != ErrUnexpectedToken {
+		t.Errorf("expected %v, got %v", ErrUnexpectedToken, diagnostics)
+	}
+}
+
+func Test_PathExpressionsWalkAndRewrite(t *testing.T) {
+	parse := func(dsl string) s.PathExpression {
+		lex := NewPathLexer(dsl).With(s.PathTokenTypes())
+		expr, err := NewPathParser(lex.Iter()).ParseExpression()
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+		return expr
+	}
+
+	var (
+		dsl  = "/event[0,1].(@A==1 or not count(colour)>=2)/colour[1:]|//ancestor::event"
+		expr = parse(dsl)
+	)
+
+	var names []string
+	expressions.Inspect(expr, func(expr s.PathExpression) bool {
+		if x, ok := expr.(s.Name); ok && expr.Type() == s.PETName {
+			names = append(names, x.Name())
+		}
+		// Skip everything within the groups.
+		return expr == nil || expr.Type() != s.PETGroup
+	})
+	if expected := []string{"event", "colour", "event"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+
+	identity := func(expr s.PathExpression) s.PathExpression {
+		return expr
+	}
+	if res := expressions.Rewrite(expr, identity); !reflect.DeepEqual(res, expr) {
+		t.Errorf("expected %v, got %v", expr, res)
+	}
+
+	rename := func(expr s.PathExpression) s.PathExpression {
+		if x, ok := expr.(s.Name); ok && expr.Type() == s.PETName && x.Name() == "colour" {
+			return expressions.MakePathName("color")
+		}
+		return expr
+	}
+	expected := parse("/event[0,1].(@A==1 or not count(color)>=2)/color[1:]|//ancestor::event")
+	if res := expressions.Rewrite(expr, rename); !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected %v, got %v", expected, res)
+	}
+}
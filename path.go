@@ -2,6 +2,7 @@ package cilli
 
 import (
 	"bufio"
+	"context"
 	"errors"
 
 	"github.com/SimonRichardson/cilli/expressions"
@@ -10,21 +11,84 @@ import (
 
 var (
 	ErrUnexpectedExpression = errors.New("Unexpected Expression")
+	ErrIteratorExhausted    = errors.New("Iterator Exhausted")
 )
 
 type PathPredicate struct {
-	Equality   func(s.Element, string, interface{}) bool
-	Inequality func(s.Element, string, interface{}) bool
+	Attribute            func(s.Element, string) (interface{}, bool)
+	Equality             func(s.Element, string, interface{}) bool
+	Inequality           func(s.Element, string, interface{}) bool
+	LessThan             func(s.Element, string, interface{}) bool
+	LessThanOrEqualTo    func(s.Element, string, interface{}) bool
+	GreaterThan          func(s.Element, string, interface{}) bool
+	GreaterThanOrEqualTo func(s.Element, string, interface{}) bool
+}
+
+// predicate returns the callback for the comparison expression type, deriving
+// it from the other callbacks where it hasn't been supplied. If the comparison
+// can't be resolved nil is returned.
+func (p PathPredicate) predicate(t s.PathExpressionType) func(s.Element, string, interface{}) bool {
+	var (
+		fn   func(s.Element, string, interface{}) bool
+		x, y func(s.Element, string, interface{}) bool
+	)
+
+	switch t {
+	case s.PETEquality:
+		fn = p.Equality
+	case s.PETInequality:
+		if fn = p.Inequality; fn == nil && p.Equality != nil {
+			fn = not(p.Equality)
+		}
+	case s.PETLessThan:
+		fn = p.LessThan
+	case s.PETLessThanOrEqualTo:
+		fn, x, y = p.LessThanOrEqualTo, p.LessThan, p.Equality
+	case s.PETGreaterThan:
+		fn = p.GreaterThan
+	case s.PETGreaterThanOrEqualTo:
+		fn, x, y = p.GreaterThanOrEqualTo, p.GreaterThan, p.Equality
+	}
+
+	if fn == nil && x != nil && y != nil {
+		fn = or(x, y)
+	}
+	return fn
+}
+
+func not(fn func(s.Element, string, interface{}) bool) func(s.Element, string, interface{}) bool {
+	return func(e s.Element, prop string, value interface{}) bool {
+		return !fn(e, prop, value)
+	}
+}
+
+func or(a, b func(s.Element, string, interface{}) bool) func(s.Element, string, interface{}) bool {
+	return func(e s.Element, prop string, value interface{}) bool {
+		return a(e, prop, value) || b(e, prop, value)
+	}
+}
+
+// PathEvaluator evaluates a custom expression type, created by a custom
+// parselet. Select is used when the expression is a step of the path and
+// returns the elements it selects from the element. Value is used when the
+// expression is within a group or is the argument of a function, if it's not
+// supplied the elements from Select are used instead.
+type PathEvaluator struct {
+	Select func(s.PathExpression, s.Element) ([]s.Element, error)
+	Value  func(s.PathExpression, s.Element) (interface{}, error)
 }
 
 type Path struct {
 	expression s.PathExpression
 	predicate  PathPredicate
+	functions  *PathFunctions
+	evaluators map[s.PathExpressionType]PathEvaluator
 }
 
 func NewPath(expression s.PathExpression) *Path {
 	return &Path{
 		expression: expression,
+		functions:  StandardPathFunctions(),
 	}
 }
 
@@ -33,6 +97,21 @@ func (p *Path) With(predicate PathPredicate) *Path {
 	return p
 }
 
+func (p *Path) WithFunctions(functions *PathFunctions) *Path {
+	p.functions = functions
+	return p
+}
+
+// WithEvaluator registers the evaluator for the custom expression type. The
+// built-in expression types can't be overridden.
+func (p *Path) WithEvaluator(expressionType s.PathExpressionType, evaluator PathEvaluator) *Path {
+	if p.evaluators == nil {
+		p.evaluators = make(map[s.PathExpressionType]PathEvaluator)
+	}
+	p.evaluators[expressionType] = evaluator
+	return p
+}
+
 func (p *Path) Describe(w *bufio.Writer) error {
 	if x, ok := p.expression.(s.Describe); ok {
 		if err := x.Describe(w); err != nil {
@@ -42,13 +121,69 @@ func (p *Path) Describe(w *bufio.Writer) error {
 	return nil
 }
 
+// Execute runs the path against the element, returning the selected elements.
+// Paths that project attribute values should use ExecuteValues instead.
 func (p *Path) Execute(element s.Element) ([]s.Element, error) {
-	var (
-		res []s.Element
+	return p.ExecuteContext(context.Background(), element, PathOptions{})
+}
 
-		nodes      = []s.Element{element}
-		expression = p.expression
-	)
+// ExecuteContext runs the path like Execute, stopping once the context has
+// been cancelled or any of the limits have been exceeded.
+func (p *Path) ExecuteContext(ctx context.Context, element s.Element, options PathOptions) ([]s.Element, error) {
+	if projects(p.expression) {
+		return nil, ErrUnexpectedExpression
+	}
+	res, err := p.ExecuteValuesContext(ctx, element, options)
+	if err != nil {
+		return nil, err
+	}
+	return res.Elements(), nil
+}
+
+// ExecuteValues runs the path against the element, returning the selected
+// elements and projected attribute values.
+func (p *Path) ExecuteValues(element s.Element) (PathResults, error) {
+	return p.ExecuteValuesContext(context.Background(), element, PathOptions{})
+}
+
+// ExecuteValuesContext runs the path like ExecuteValues, with the context and
+// limits of ExecuteContext.
+func (p *Path) ExecuteValuesContext(ctx context.Context, element s.Element, options PathOptions) (PathResults, error) {
+	var res PathResults
+	for iter := p.IterContext(ctx, element, options); iter.HasNext(); {
+		result, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, result)
+	}
+	return res, nil
+}
+
+// Iter runs the path against the element lazily, the tree is only traversed as
+// the results are pulled from the iterator. Any error is returned from Next.
+func (p *Path) Iter(element s.Element) PathResultIterator {
+	return p.IterContext(context.Background(), element, PathOptions{})
+}
+
+// IterContext runs the path like Iter, with the context and limits of
+// ExecuteContext.
+func (p *Path) IterContext(ctx context.Context, element s.Element, options PathOptions) PathResultIterator {
+	limits := newPathLimits(ctx, options)
+
+	stream, err := p.run(p.expression, streamOf(makePathNode(element, limits)))
+	if err != nil {
+		stream = func() (PathResult, bool, error) {
+			return PathResult{}, false, err
+		}
+	}
+	return &pathResultIterator{
+		stream: limits.results(stream),
+	}
+}
+
+func (p *Path) run(expression s.PathExpression, nodes nodeStream) (resultStream, error) {
+	var res resultStream
 
 	// Add context to shortcuts
 	switch expression.Type() {
@@ -61,8 +196,39 @@ loop:
 	for {
 		switch expression.Type() {
 		case s.PETWildcard:
-			res = nodes
+			res = elements(nodes)
+			break loop
+		case s.PETUnion:
+			if x, ok := left(expression); ok {
+				if y, ok := right(expression); ok {
+					shared := replay(nodes)
+					a, err := p.run(x, shared())
+					if err != nil {
+						return nil, err
+					}
+					b, err := p.run(y, shared())
+					if err != nil {
+						return nil, err
+					}
+					res = union(a, b)
+					break loop
+				}
+			}
+			return nil, ErrUnexpectedExpression
+		case s.PETAttribute:
+			res = p.project(expression, nodes)
 			break loop
+		case s.PETAxis:
+			res = elements(axis(expression, nodes))
+			break loop
+		case s.PETInfixAttribute:
+			if x, ok := left(expression); ok && x.Type() == s.PETName {
+				if y, ok := right(expression); ok {
+					res = p.project(expressions.MakePathNamedAttribute(y), filterByName(x, nodes))
+					break loop
+				}
+			}
+			return nil, ErrUnexpectedExpression
 		case s.PETAllDescendants:
 			nodes = getAllChildren(nodes)
 			if expr, ok := descendants(expression); ok {
@@ -82,6 +248,10 @@ loop:
 				switch x.Type() {
 				case s.PETName:
 					nodes = filterByName(x, nodes)
+				case s.PETWildcard:
+					// Every node matches, such as *.(@A==1)
+				case s.PETAxis:
+					nodes = axis(x, nodes)
 				case s.PETIndexAccess:
 					if y, ok := left(x); ok {
 						nodes = filterByName(y, nodes)
@@ -93,36 +263,53 @@ loop:
 					}
 					return nil, ErrUnexpectedExpression
 				case s.PETGroup:
-					if n, _, ok := group(p.predicate, x, nodes); ok {
-						nodes = n
-						break
+					n, _, err := p.group(x, nodes)
+					if err != nil {
+						return nil, err
 					}
-					return nil, ErrUnexpectedExpression
+					nodes = n
 				default:
-					return nil, ErrUnexpectedExpression
+					fn, ok := p.selector(x)
+					if !ok {
+						return nil, ErrUnexpectedExpression
+					}
+					nodes = p.selectBy(fn, x, nodes)
 				}
 
 				if y, ok := right(expression); ok {
+					if p.axial(y) {
+						expression = y
+						continue loop
+					}
+
 					switch y.Type() {
 					case s.PETName:
 						expression = expressions.MakePathDescendants(s.PDTContext, y)
-					case s.PETNameDescendants, s.PETInstance:
+					case s.PETNameDescendants, s.PETInstance, s.PETInfixAttribute:
 						nodes = getContextChildren(nodes)
 						expression = y
-					case s.PETWildcard, s.PETbranch:
+					case s.PETWildcard, s.PETbranch, s.PETAttribute:
 						expression = y
+					case s.PETDescendants, s.PETAllDescendants:
+						// a//b reads the second slash as the start of a path,
+						// which selects from all the descendants of a.
+						z, ok := descendants(y)
+						if !ok {
+							return nil, ErrUnexpectedExpression
+						}
+						expression = expressions.MakePathDescendants(s.PDTAll, z)
 					case s.PETIndexAccess:
 						expression = expressions.MakePathDescendants(
 							s.PDTContext,
 							expressions.MakePathNameDescendants(y, expressions.MakePathWildcard()),
 						)
 					case s.PETGroup:
-						if n, expr, ok := group(p.predicate, y, nodes); ok {
-							nodes = n
-							expression = expr
-							continue loop
+						n, expr, err := p.group(y, nodes)
+						if err != nil {
+							return nil, err
 						}
-						return nil, ErrUnexpectedExpression
+						nodes = n
+						expression = expr
 					default:
 						return nil, ErrUnexpectedExpression
 					}
@@ -131,31 +318,184 @@ loop:
 			}
 			return nil, ErrUnexpectedExpression
 		case s.PETName:
-			res = filterByName(expression, nodes)
+			res = elements(filterByName(expression, nodes))
 			break loop
+		case s.PETIndexAccess:
+			if x, ok := left(expression); ok {
+				if y, ok := right(expression); ok {
+					res = elements(filterByIndex(y, filterByName(x, nodes)))
+					break loop
+				}
+			}
+			return nil, ErrUnexpectedExpression
 		default:
+			if fn, ok := p.selector(expression); ok {
+				res = elements(p.selectBy(fn, expression, nodes))
+				break loop
+			}
 			return nil, ErrUnexpectedExpression
 		}
 	}
 	return res, nil
 }
 
-func getAllChildren(nodes []s.Element) []s.Element {
-	res := make([]s.Element, 0)
-	for _, v := range nodes {
-		children := v.Children()
-		res = append(append(res, children...), getAllChildren(children)...)
+// projects reports if the path ends by projecting attribute values, which is
+// known from the expression alone, whether or not anything matches.
+func projects(expression s.PathExpression) bool {
+	switch expression.Type() {
+	case s.PETAttribute, s.PETInfixAttribute:
+		return true
+	case s.PETUnion:
+		if x, ok := left(expression); ok {
+			if y, ok := right(expression); ok {
+				return projects(x) || projects(y)
+			}
+		}
+	case s.PETDescendants, s.PETAllDescendants:
+		if x, ok := descendants(expression); ok {
+			return projects(x)
+		}
+	case s.PETNameDescendants, s.PETInstance, s.PETbranch:
+		if x, ok := right(expression); ok {
+			return projects(x)
+		}
+	}
+	return false
+}
+
+// project the attribute values from the nodes, elements without the attribute
+// are skipped. A wildcard attribute projects every attribute of the element.
+func (p *Path) project(expression s.PathExpression, nodes nodeStream) resultStream {
+	var names func(*pathNode) []string
 
+	if x, ok := operand(expression); ok && x.Type() == s.PETWildcard {
+		names = func(node *pathNode) []string {
+			if y, ok := node.element.(s.Attributes); ok {
+				return y.Attributes()
+			}
+			return nil
+		}
+	} else if x, ok := expression.(s.Name); ok {
+		name := []string{x.Name()}
+		names = func(*pathNode) []string {
+			return name
+		}
+	} else {
+		return emptyResults
+	}
+
+	var (
+		node    *pathNode
+		pending []string
+	)
+	return func() (PathResult, bool, error) {
+		for {
+			for len(pending) > 0 {
+				name := pending[0]
+				pending = pending[1:]
+				if value, ok := p.attribute(node.element, name); ok {
+					res := MakePathAttributeResult(node.element, name, value)
+					res.node = node
+					return res, true, nil
+				}
+			}
+
+			next, ok, err := nodes()
+			if err != nil || !ok {
+				return PathResult{}, false, err
+			}
+			node, pending = next, names(next)
+		}
 	}
-	return res
 }
 
-func getContextChildren(nodes []s.Element) []s.Element {
-	res := make([]s.Element, 0)
-	for _, v := range nodes {
-		res = append(res, v.Children()...)
+// getAllChildren walks the descendants of every node, each node yielding all
+// of its children before the descendants of the first child.
+func getAllChildren(nodes nodeStream) nodeStream {
+	type step struct {
+		node  *pathNode
+		depth int
 	}
-	return res
+
+	return expand(nodes, func(node *pathNode) nodeStream {
+		var (
+			pending = []step{{node, 0}}
+			buffer  []*pathNode
+		)
+		return func() (*pathNode, bool, error) {
+			for len(buffer) == 0 {
+				if len(pending) == 0 {
+					return nil, false, nil
+				}
+
+				next := pending[len(pending)-1]
+				pending = pending[:len(pending)-1]
+
+				children, err := next.node.children()
+				if err != nil {
+					return nil, false, err
+				}
+				if len(children) > 0 {
+					if err := node.limits.descend(next.depth + 1); err != nil {
+						return nil, false, err
+					}
+				}
+
+				buffer = children
+				for k := len(buffer) - 1; k >= 0; k-- {
+					pending = append(pending, step{buffer[k], next.depth + 1})
+				}
+			}
+
+			res := buffer[0]
+			buffer = buffer[1:]
+			return res, true, nil
+		}
+	})
+}
+
+func (p *Path) selector(expression s.PathExpression) (func(s.PathExpression, s.Element) ([]s.Element, error), bool) {
+	if x, ok := p.evaluators[expression.Type()]; ok && x.Select != nil {
+		return x.Select, true
+	}
+	return nil, false
+}
+
+// selectBy selects the nodes using a custom evaluator, like the axes it's
+// applied to the current nodes and not their children.
+func (p *Path) selectBy(fn func(s.PathExpression, s.Element) ([]s.Element, error),
+	expression s.PathExpression,
+	nodes nodeStream,
+) nodeStream {
+	return expand(nodes, func(node *pathNode) nodeStream {
+		if err := node.limits.check(); err != nil {
+			return failed(err)
+		}
+
+		elements, err := fn(expression, node.element)
+		if err != nil {
+			return failed(err)
+		}
+		if err := node.limits.visit(len(elements)); err != nil {
+			return failed(err)
+		}
+
+		res := make([]*pathNode, len(elements))
+		for k, v := range elements {
+			res[k] = makePathNode(v, node.limits)
+		}
+		return streamOf(res...)
+	})
+}
+
+func getContextChildren(nodes nodeStream) nodeStream {
+	return expand(nodes, func(node *pathNode) nodeStream {
+		children, err := node.children()
+		if err != nil {
+			return failed(err)
+		}
+		return streamOf(children...)
+	})
 }
 
 func descendants(expression s.PathExpression) (s.PathExpression, bool) {
@@ -173,6 +513,13 @@ func list(expression s.PathExpression) ([]s.PathExpression, bool) {
 	return nil, false
 }
 
+func operand(expression s.PathExpression) (s.PathExpression, bool) {
+	if expr, ok := expression.(s.Unary); ok {
+		return expr.Operand(), true
+	}
+	return nil, false
+}
+
 func left(expression s.PathExpression) (s.PathExpression, bool) {
 	if expr, ok := expression.(s.Branch); ok {
 		expression = expr.Left()
@@ -189,74 +536,262 @@ func right(expression s.PathExpression) (s.PathExpression, bool) {
 	return nil, false
 }
 
-func group(predicates PathPredicate, expr s.PathExpression, nodes []s.Element) ([]s.Element, s.PathExpression, bool) {
-	if exprs, ok := list(expr); ok {
-	loop:
-		for k, v := range exprs {
-			switch v.Type() {
-			case s.PETAttribute:
-				if next, ok := peek(exprs, k+1); ok && validAttribute(next) {
-					continue loop
+func (p *Path) group(expr s.PathExpression, nodes nodeStream) (nodeStream, s.PathExpression, error) {
+	if _, ok := list(expr); ok {
+		res := filter(nodes, func(node *pathNode) (bool, error) {
+			return p.evaluate(expr, node)
+		})
+		return res, expressions.MakePathWildcard(), nil
+	}
+
+	return nil, nil, ErrUnexpectedExpression
+}
+
+// evaluate resolves a boolean expression against a single element. Entries of
+// a group are implicitly and'ed together and a bare attribute tests for the
+// existence of the attribute.
+func (p *Path) evaluate(expr s.PathExpression, node *pathNode) (bool, error) {
+	switch expr.Type() {
+	case s.PETGroup:
+		if exprs, ok := list(expr); ok {
+			for _, v := range exprs {
+				if match, err := p.evaluate(v, node); err != nil || !match {
+					return false, err
 				}
-				return nil, nil, false
-			case s.PETEquality:
-				if x, ok := left(v); ok {
-					if y, ok := right(v); ok {
-						nodes = filterByPredicate(predicates.Equality, x, y, nodes)
-						continue loop
-					}
+			}
+			return true, nil
+		}
+	case s.PETLogicalAnd:
+		if x, ok := left(expr); ok {
+			if y, ok := right(expr); ok {
+				if match, err := p.evaluate(x, node); err != nil || !match {
+					return false, err
+				}
+				return p.evaluate(y, node)
+			}
+		}
+	case s.PETLogicalOr:
+		if x, ok := left(expr); ok {
+			if y, ok := right(expr); ok {
+				if match, err := p.evaluate(x, node); err != nil || match {
+					return match, err
 				}
-			default:
-				return nil, nil, false
+				return p.evaluate(y, node)
 			}
 		}
+	case s.PETLogicalNot:
+		if x, ok := operand(expr); ok {
+			match, err := p.evaluate(x, node)
+			return !match, err
+		}
+	case s.PETEquality,
+		s.PETInequality,
+		s.PETLessThan,
+		s.PETLessThanOrEqualTo,
+		s.PETGreaterThan,
+		s.PETGreaterThanOrEqualTo:
+		if x, ok := left(expr); ok {
+			if y, ok := right(expr); ok {
+				// The callbacks only compare an attribute with a literal.
+				if _, ok := y.(s.Value); ok && x.Type() == s.PETAttribute {
+					if fn := p.predicate.predicate(expr.Type()); fn != nil {
+						return predicate(fn, x, y, node.element)
+					}
+				}
 
-		return nodes, expressions.MakePathWildcard(), true
+				a, err := p.value(x, node)
+				if err != nil {
+					return false, err
+				}
+				b, err := p.value(y, node)
+				if err != nil {
+					return false, err
+				}
+				return compare(expr.Type(), a, b), nil
+			}
+		}
+	case s.PETAttribute:
+		if x, ok := expr.(s.Name); ok {
+			_, ok := p.attribute(node.element, x.Name())
+			return ok, nil
+		}
+	default:
+		res, err := p.value(expr, node)
+		if err != nil {
+			return false, err
+		}
+		return toBoolean(res), nil
 	}
-
-	return nil, nil, false
+	return false, ErrUnexpectedExpression
 }
 
-func peek(exprs []s.PathExpression, pos int) (s.PathExpression, bool) {
-	if num := len(exprs); pos >= 0 && pos < num {
-		return exprs[pos], true
+// attribute looks up the named attribute of the element, using the predicate
+// callback if one has been supplied, falling back to the element itself when
+// the callback doesn't know of the attribute.
+func (p *Path) attribute(element s.Element, name string) (interface{}, bool) {
+	if p.predicate.Attribute != nil {
+		if res, ok := p.predicate.Attribute(element, name); ok {
+			return res, true
+		}
+	}
+	if x, ok := element.(s.Attributes); ok {
+		return x.Attribute(name)
 	}
 	return nil, false
 }
 
-func validAttribute(expr s.PathExpression) bool {
-	// A valid attribute should always have a left hand side of name.
+// value resolves an expression to a value for a single element. Literals
+// resolve to themselves, attributes are looked up, function calls are invoked
+// and anything else is treated as a path relative to the element.
+func (p *Path) value(expr s.PathExpression, node *pathNode) (interface{}, error) {
 	switch expr.Type() {
-	case s.PETEquality:
-		if x, ok := left(expr); ok && x.Type() == s.PETName {
-			return true
+	case s.PETString, s.PETNumber, s.PETInteger, s.PETBoolean:
+		return literal(expr), nil
+	case s.PETAttribute:
+		if x, ok := expr.(s.Name); ok {
+			res, _ := p.attribute(node.element, x.Name())
+			return res, nil
 		}
+		return nil, ErrUnexpectedExpression
+	case s.PETMethodCall:
+		return p.call(expr, node)
+	case s.PETGroup,
+		s.PETLogicalAnd,
+		s.PETLogicalOr,
+		s.PETLogicalNot,
+		s.PETEquality,
+		s.PETInequality,
+		s.PETLessThan,
+		s.PETLessThanOrEqualTo,
+		s.PETGreaterThan,
+		s.PETGreaterThanOrEqualTo:
+		return p.evaluate(expr, node)
 	}
-	return false
+
+	if x, ok := p.evaluators[expr.Type()]; ok && x.Value != nil {
+		return x.Value(expr, node.element)
+	}
+
+	stream, err := p.run(expr, streamOf(node))
+	if err != nil {
+		return nil, err
+	}
+	res, err := collectResults(stream)
+	if err != nil {
+		return nil, err
+	}
+	if projects(expr) {
+		// Only the first value is used when comparing projected attributes.
+		if len(res) > 0 {
+			return res[0].Value(), nil
+		}
+		return nil, nil
+	}
+	return res.Elements(), nil
 }
 
-func filterByName(expression s.PathExpression, nodes []s.Element) []s.Element {
-	var res []s.Element
+func (p *Path) call(expr s.PathExpression, node *pathNode) (interface{}, error) {
+	x, ok := expr.(s.MethodCall)
+	if !ok {
+		return nil, ErrUnexpectedExpression
+	}
+
+	name, ok := x.Method().(s.Name)
+	if !ok {
+		return nil, ErrUnexpectedExpression
+	}
 
-	if expr, ok := expression.(s.Name); ok {
-		name := expr.Name()
+	fn, ok := p.functions.Lookup(name.Name())
+	if !ok {
+		return nil, ErrUnknownFunction
+	}
 
-		for _, v := range nodes {
-			if v.Name() == name {
-				res = append(res, v)
-			}
+	params := x.Parameters()
+	if !fn.accepts(len(params)) {
+		return nil, ErrInvalidArity
+	}
+
+	args := make([]interface{}, len(params))
+	for k, v := range params {
+		arg, err := p.value(v, node)
+		if err != nil {
+			return nil, err
+		}
+		if args[k], err = coerce(arg, fn.argument(k)); err != nil {
+			return nil, err
 		}
 	}
-	return res
+
+	return fn.Call(node.element, args)
 }
 
-func filterByIndex(expression s.PathExpression, nodes []s.Element) []s.Element {
-	var res []s.Element
+func filterByName(expression s.PathExpression, nodes nodeStream) nodeStream {
+	expr, ok := expression.(s.Name)
+	if !ok {
+		return streamOf()
+	}
 
-	if expr, ok := expression.(s.Index); ok {
+	name := expr.Name()
+	return filter(nodes, func(node *pathNode) (bool, error) {
+		return node.element.Name() == name, nil
+	})
+}
+
+// filterByIndex selects the nodes by their index. Only a positive single index
+// is selected as the nodes are pulled, anything else needs every node first.
+func filterByIndex(expression s.PathExpression, nodes nodeStream) nodeStream {
+	if expr, ok := expression.(s.Index); ok && expr.Index() >= 0 {
 		index := expr.Index()
+		return func() (*pathNode, bool, error) {
+			for ; index > 0; index-- {
+				if _, ok, err := nodes(); err != nil || !ok {
+					return nil, false, err
+				}
+			}
+			if index < 0 {
+				return nil, false, nil
+			}
+
+			index = -1
+			return nodes()
+		}
+	}
+
+	return deferred(func() ([]*pathNode, error) {
+		res, err := collect(nodes)
+		if err != nil {
+			return nil, err
+		}
+		return indexes(expression, res), nil
+	})
+}
 
-		if num := len(nodes); index >= 0 && index < num {
+// indexes selects nodes by a single index, a list of indexes or a slice.
+// Negative indexes count back from the end of the nodes and slices follow the
+// start:end:step semantics of Python.
+func indexes(expression s.PathExpression, nodes []*pathNode) []*pathNode {
+	var (
+		res []*pathNode
+		num = len(nodes)
+	)
+
+	switch expression.Type() {
+	case s.PETSlice:
+		if expr, ok := expression.(s.Slice); ok {
+			for _, v := range sliceIndexes(num, expr) {
+				res = append(res, nodes[v])
+			}
+		}
+	case s.PETIndexList:
+		if exprs, ok := list(expression); ok {
+			for _, v := range exprs {
+				if index, ok := nodeIndex(v, num); ok {
+					res = append(res, nodes[index])
+				}
+			}
+		}
+	default:
+		if index, ok := nodeIndex(expression, num); ok {
 			res = append(res, nodes[index])
 		}
 	}
@@ -264,25 +799,90 @@ func filterByIndex(expression s.PathExpression, nodes []s.Element) []s.Element {
 	return res
 }
 
-func filterByPredicate(predicate func(s.Element, string, interface{}) bool,
-	left, right s.PathExpression,
-	nodes []s.Element,
-) []s.Element {
-	var res []s.Element
+func nodeIndex(expression s.PathExpression, num int) (int, bool) {
+	if expr, ok := expression.(s.Index); ok {
+		index := expr.Index()
+		if index < 0 {
+			index += num
+		}
+		if index >= 0 && index < num {
+			return index, true
+		}
+	}
+	return 0, false
+}
 
-	if x, ok := left.(s.Name); ok {
-		prop := x.Name()
+func sliceIndexes(num int, expr s.Slice) []int {
+	bound := func(expression s.PathExpression, def int) (int, bool) {
+		if expression == nil {
+			return def, true
+		}
+		if x, ok := expression.(s.Index); ok {
+			return x.Index(), true
+		}
+		return 0, false
+	}
 
-		if y, ok := right.(s.Value); ok {
-			value := y.Value()
+	step, ok := bound(expr.Step(), 1)
+	if !ok || step == 0 {
+		return nil
+	}
 
-			for _, v := range nodes {
-				if predicate(v, prop, value) {
-					res = append(res, v)
-				}
-			}
+	// Omitted bounds depend on the direction of the step, which is why
+	// clamping has a different lower bound when going backwards.
+	var (
+		lower, upper = 0, num
+		first, last  = 0, num
+	)
+	if step < 0 {
+		lower, upper = -1, num-1
+		first, last = num-1, -1
+	}
+
+	clamp := func(expression s.PathExpression, def int) (int, bool) {
+		if expression == nil {
+			return def, true
+		}
+		index, ok := bound(expression, def)
+		if !ok {
+			return 0, false
 		}
+		if index < 0 {
+			index += num
+		}
+		if index < lower {
+			index = lower
+		}
+		if index > upper {
+			index = upper
+		}
+		return index, true
 	}
 
+	start, ok := clamp(expr.Start(), first)
+	if !ok {
+		return nil
+	}
+	end, ok := clamp(expr.End(), last)
+	if !ok {
+		return nil
+	}
+
+	var res []int
+	for i := start; (step > 0 && i < end) || (step < 0 && i > end); i += step {
+		res = append(res, i)
+	}
 	return res
 }
+
+func predicate(fn func(s.Element, string, interface{}) bool,
+	left, right s.PathExpression,
+	element s.Element,
+) (bool, error) {
+	if x, ok := left.(s.Name); ok {
+		if y, ok := right.(s.Value); ok {
+			return fn(element, x.Name(), y.Value()), nil
+		}
+	}
+	return false, ErrUnexpectedExpression
+}
@@ -3,10 +3,14 @@ package cilli
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
-	"strconv"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 	"testing/quick"
+	"time"
 
 	s "github.com/SimonRichardson/cilli/selectors"
 )
@@ -32,8 +36,7 @@ func Test_PathDescribe(t *testing.T) {
 			path.Describe(writer)
 			writer.Flush()
 
-			res, _ := strconv.Unquote(buffer.String())
-			return res
+			return buffer.String()
 		}
 		g = func(a string) string {
 			return fmt.Sprintf("%q", a)
@@ -574,12 +577,7 @@ func Test_PathExecuteForwardSlashWithIndexThenNameAndGroupEquality(t *testing.T)
 
 			path := NewPath(expr).With(PathPredicate{
 				Equality: func(elem s.Element, prop string, value interface{}) bool {
-					if prop == "Name" {
-						if str, err := strconv.Unquote(value.(string)); err == nil {
-							return elem.Name() == str
-						}
-					}
-					return false
+					return prop == "Name" && elem.Name() == value
 				},
 			})
 			res, err := path.Execute(MakeElement("root", func() []s.Element {
@@ -617,12 +615,7 @@ func Test_PathExecuteForwardSlashWithDoubleGroupEquality(t *testing.T) {
 
 			path := NewPath(expr).With(PathPredicate{
 				Equality: func(elem s.Element, prop string, value interface{}) bool {
-					if prop == "Name" {
-						if str, err := strconv.Unquote(value.(string)); err == nil {
-							return elem.Name() == str
-						}
-					}
-					return false
+					return prop == "Name" && elem.Name() == value
 				},
 			})
 			res, err := path.Execute(MakeElement("root", func() []s.Element {
@@ -644,3 +637,885 @@ func Test_PathExecuteForwardSlashWithDoubleGroupEquality(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+type priorityElement struct {
+	element
+	priority float64
+}
+
+func MakeElementsWithPriority(amount uint) []s.Element {
+	var (
+		x   = int(amount)
+		res = make([]s.Element, x, x)
+	)
+	for i := 0; i < x; i++ {
+		res[i] = priorityElement{
+			element: element{"node", func() []s.Element {
+				return []s.Element{}
+			}},
+			priority: float64(i),
+		}
+	}
+	return res
+}
+
+func priority(fn func(float64, float64) bool) func(s.Element, string, interface{}) bool {
+	return func(elem s.Element, prop string, value interface{}) bool {
+		if x, ok := elem.(priorityElement); ok && prop == "Priority" {
+			if y, ok := value.(float64); ok {
+				return fn(x.priority, y)
+			}
+		}
+		return false
+	}
+}
+
+func Test_PathExecuteForwardSlashWithGroupComparisons(t *testing.T) {
+	predicate := PathPredicate{
+		Equality:    priority(func(a, b float64) bool { return a == b }),
+		LessThan:    priority(func(a, b float64) bool { return a < b }),
+		GreaterThan: priority(func(a, b float64) bool { return a > b }),
+	}
+
+	for dsl, expected := range map[string]int{
+		"/node.(@Priority==3)": 1,
+		"/node.(@Priority!=3)": 9,
+		"/node.(@Priority<3)":  3,
+		"/node.(@Priority<=3)": 4,
+		"/node.(@Priority>3)":  6,
+		"/node.(@Priority>=3)": 7,
+	} {
+		var (
+			lex       = NewPathLexer(dsl).With(s.PathTokenTypes())
+			parser    = NewPathParser(lex.Iter())
+			expr, err = parser.ParseExpression()
+		)
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+
+		path := NewPath(expr).With(predicate)
+		res, err := path.Execute(MakeElement("root", func() []s.Element {
+			return MakeElementsWithPriority(10)
+		}))
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+
+		if len(res) != expected {
+			t.Errorf("%s: expected %d, got %d", dsl, expected, len(res))
+		}
+	}
+}
+
+func Test_PathExecuteForwardSlashWithGroupLogicalOperators(t *testing.T) {
+	predicate := PathPredicate{
+		Equality:    priority(func(a, b float64) bool { return a == b }),
+		LessThan:    priority(func(a, b float64) bool { return a < b }),
+		GreaterThan: priority(func(a, b float64) bool { return a > b }),
+	}
+
+	for dsl, expected := range map[string]int{
+		"/node.(@Priority>2 && @Priority<5)":                   2,
+		"/node.(@Priority<2 || @Priority>=8)":                  4,
+		"/node.(!@Priority==3)":                                9,
+		"/node.(!(@Priority<2 || @Priority>7))":                6,
+		"/node.(@Priority==0 || @Priority>2 && @Priority<5)":   3,
+		"/node.((@Priority==0 || @Priority>2) && @Priority<5)": 3,
+		"/node.((@Priority==9 || @Priority>2) && @Priority<5)": 2,
+		"/node.(true)":  10,
+		"/node.(!true)": 0,
+	} {
+		var (
+			lex       = NewPathLexer(dsl).With(s.PathTokenTypes())
+			parser    = NewPathParser(lex.Iter())
+			expr, err = parser.ParseExpression()
+		)
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+
+		path := NewPath(expr).With(predicate)
+		res, err := path.Execute(MakeElement("root", func() []s.Element {
+			return MakeElementsWithPriority(10)
+		}))
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+
+		if len(res) != expected {
+			t.Errorf("%s: expected %d, got %d", dsl, expected, len(res))
+		}
+	}
+}
+
+type attributesElement struct {
+	element
+	attributes map[string]interface{}
+}
+
+func MakeElementWithAttributes(name string, attributes map[string]interface{}) s.Element {
+	return MakeElementWithAttributesAndChildren(name, attributes, func() []s.Element {
+		return []s.Element{}
+	})
+}
+
+func MakeElementWithAttributesAndChildren(name string,
+	attributes map[string]interface{},
+	children func() []s.Element,
+) s.Element {
+	return attributesElement{
+		element:    element{name, children},
+		attributes: attributes,
+	}
+}
+
+func (e attributesElement) Attribute(name string) (interface{}, bool) {
+	value, ok := e.attributes[name]
+	return value, ok
+}
+
+func (e attributesElement) Attributes() []string {
+	res := make([]string, 0, len(e.attributes))
+	for k := range e.attributes {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func MakeEvents() s.Element {
+	return MakeElement("root", func() []s.Element {
+		return []s.Element{
+			MakeElementWithAttributes("event", map[string]interface{}{
+				"Priority": 1,
+				"State":    "open",
+				"Urgent":   true,
+			}),
+			MakeElementWithAttributes("event", map[string]interface{}{
+				"Priority": 3.5,
+				"State":    "closed",
+			}),
+			MakeElementWithAttributes("event", map[string]interface{}{
+				"Priority": int64(5),
+				"State":    "open",
+				"Urgent":   false,
+			}),
+		}
+	})
+}
+
+func Test_PathExecuteForwardSlashWithElementAttributes(t *testing.T) {
+	for dsl, expected := range map[string]int{
+		"/event.(@Priority>=3)":                     2,
+		"/event.(@Priority==1)":                     1,
+		"/event.(@Priority<3.5)":                    1,
+		"/event.(@State!=\"closed\")":               2,
+		"/event.(@State==\"open\" && @Priority>2)":  1,
+		"/event.(@Urgent)":                          2,
+		"/event.(!@Urgent)":                         1,
+		"/event.(@Urgent==true)":                    1,
+		"/event.(@Missing==1 || @Missing!=1)":       0,
+		"/event.(starts_with(@State, \"clo\"))":     1,
+		"/event.(@State>\"closed\" && @Priority<5)": 1,
+	} {
+		var (
+			lex       = NewPathLexer(dsl).With(s.PathTokenTypes())
+			parser    = NewPathParser(lex.Iter())
+			expr, err = parser.ParseExpression()
+		)
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+
+		res, err := NewPath(expr).Execute(MakeEvents())
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+
+		if len(res) != expected {
+			t.Errorf("%s: expected %d, got %d", dsl, expected, len(res))
+		}
+	}
+}
+
+func Test_PathExecuteForwardSlashWithPredicateOverridingAttributes(t *testing.T) {
+	var (
+		lex       = NewPathLexer("/event.(@State==\"open\")").With(s.PathTokenTypes())
+		parser    = NewPathParser(lex.Iter())
+		expr, err = parser.ParseExpression()
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := NewPath(expr).With(PathPredicate{
+		Equality: func(elem s.Element, prop string, value interface{}) bool {
+			return true
+		},
+	})
+	res, err := path.Execute(MakeEvents())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 3 {
+		t.Errorf("expected 3, got %d", len(res))
+	}
+}
+
+func Test_PathExecuteForwardSlashWithAttributeCallbackAndElementAttributes(t *testing.T) {
+	path := func(dsl string) *Path {
+		var (
+			lex       = NewPathLexer(dsl).With(s.PathTokenTypes())
+			parser    = NewPathParser(lex.Iter())
+			expr, err = parser.ParseExpression()
+		)
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+		return NewPath(expr).With(PathPredicate{
+			Attribute: func(elem s.Element, prop string) (interface{}, bool) {
+				if prop == "Title" {
+					return "outage", true
+				}
+				return nil, false
+			},
+		})
+	}
+
+	for dsl, expected := range map[string]int{
+		`/event.(@Title=="outage")`:                 3,
+		`/event.(@State=="open")`:                   2,
+		`/event.(@Title=="outage" && @Priority>=3)`: 2,
+		`/event.(@Missing)`:                         0,
+	} {
+		res, err := path(dsl).Execute(MakeEvents())
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+
+		if len(res) != expected {
+			t.Errorf("%s: expected %d, got %d", dsl, expected, len(res))
+		}
+	}
+}
+
+func MakeColouredEvents() s.Element {
+	colour := func(red, green int) func() []s.Element {
+		return func() []s.Element {
+			return []s.Element{
+				MakeElementWithAttributes("colour", map[string]interface{}{
+					"Red":   red,
+					"Green": green,
+				}),
+			}
+		}
+	}
+	return MakeElement("root", func() []s.Element {
+		return []s.Element{
+			MakeElementWithAttributesAndChildren("event", map[string]interface{}{
+				"Date":  "2017-03-10",
+				"Title": "first",
+			}, colour(20, 40)),
+			MakeElementWithAttributesAndChildren("event", map[string]interface{}{
+				"Date":  "2017-03-11",
+				"Title": "second",
+			}, colour(30, 50)),
+		}
+	})
+}
+
+func Test_PathExecuteValuesWithAttributeProjection(t *testing.T) {
+	for dsl, expected := range map[string][]interface{}{
+		"/event/colour/@Red":                       []interface{}{20, 30},
+		"/event/colour@Green":                      []interface{}{40, 50},
+		"/event.(@Date==\"2017-03-10\")/@Title":    []interface{}{"first"},
+		"/event.(@Date==\"2017-03-11\")/colour/@*": []interface{}{50, 30},
+		"/event/@Missing":                          nil,
+	} {
+		var (
+			lex       = NewPathLexer(dsl).With(s.PathTokenTypes())
+			parser    = NewPathParser(lex.Iter())
+			expr, err = parser.ParseExpression()
+		)
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+
+		res, err := NewPath(expr).ExecuteValues(MakeColouredEvents())
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+
+		if values := res.Values(); len(values) != len(expected) || (len(values) > 0 && !reflect.DeepEqual(values, expected)) {
+			t.Errorf("%s: expected %v, got %v", dsl, expected, values)
+		}
+		for _, v := range res {
+			if !v.IsAttribute() {
+				t.Errorf("%s: expected attribute, got %v", dsl, v.Element())
+			}
+		}
+	}
+}
+
+func Test_PathExecuteWithAttributeProjection(t *testing.T) {
+	var (
+		lex       = NewPathLexer("/event/colour/@Red").With(s.PathTokenTypes())
+		parser    = NewPathParser(lex.Iter())
+		expr, err = parser.ParseExpression()
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewPath(expr).Execute(MakeColouredEvents()); err != ErrUnexpectedExpression {
+		t.Errorf("expected %v, got %v", ErrUnexpectedExpression, err)
+	}
+
+	// The projection is rejected even when there's nothing to project.
+	for _, dsl := range []string{"/event/@Missing", "/missing/colour@Red", "/missing | /event/@Red"} {
+		lex := NewPathLexer(dsl).With(s.PathTokenTypes())
+		expr, err := NewPathParser(lex.Iter()).ParseExpression()
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+		if _, err := NewPath(expr).Execute(MakeColouredEvents()); err != ErrUnexpectedExpression {
+			t.Errorf("%s: expected %v, got %v", dsl, ErrUnexpectedExpression, err)
+		}
+	}
+
+	res, err := NewPath(expr).ExecuteValues(MakeColouredEvents())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range res {
+		if v.Name() != "Red" || v.Element().Name() != "colour" {
+			t.Errorf("expected Red of colour, got %s of %s", v.Name(), v.Element().Name())
+		}
+	}
+}
+
+func Test_PathExecuteForwardSlashWithSliceAccess(t *testing.T) {
+	for dsl, expected := range map[string][]float64{
+		"/node[-1]":     {9},
+		"/node[-10]":    {0},
+		"/node[-11]":    nil,
+		"/node[10]":     nil,
+		"/node[1:5]":    {1, 2, 3, 4},
+		"/node[:3]":     {0, 1, 2},
+		"/node[-2:]":    {8, 9},
+		"/node[::3]":    {0, 3, 6, 9},
+		"/node[::-4]":   {9, 5, 1},
+		"/node[7:2:-2]": {7, 5, 3},
+		"/node[5:100]":  {5, 6, 7, 8, 9},
+		"/node[::0]":    nil,
+		"/node[0,2,-1]": {0, 2, 9},
+	} {
+		var (
+			lex       = NewPathLexer(dsl).With(s.PathTokenTypes())
+			parser    = NewPathParser(lex.Iter())
+			expr, err = parser.ParseExpression()
+		)
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+
+		res, err := NewPath(expr).Execute(MakeElement("root", func() []s.Element {
+			return MakeElementsWithPriority(10)
+		}))
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+
+		var priorities []float64
+		for _, v := range res {
+			priorities = append(priorities, v.(priorityElement).priority)
+		}
+		if !reflect.DeepEqual(priorities, expected) {
+			t.Errorf("%s: expected %v, got %v", dsl, expected, priorities)
+		}
+	}
+}
+
+type pointerElement struct {
+	name     string
+	children []s.Element
+}
+
+func (e *pointerElement) Name() string {
+	return e.name
+}
+
+func (e *pointerElement) Children() []s.Element {
+	return e.children
+}
+
+type identifiedElement struct {
+	element
+	id string
+}
+
+func (e identifiedElement) ID() string {
+	return e.id
+}
+
+func Test_PathExecuteWithUnion(t *testing.T) {
+	var (
+		colour = &pointerElement{name: "colour"}
+		shape  = &pointerElement{name: "shape"}
+		root   = &pointerElement{name: "root", children: []s.Element{
+			&pointerElement{name: "event", children: []s.Element{colour, shape}},
+		}}
+		none = func() []s.Element {
+			return []s.Element{}
+		}
+		values = MakeElement("root", func() []s.Element {
+			return []s.Element{
+				MakeElement("event", none),
+				identifiedElement{element{"shape", none}, "a"},
+				identifiedElement{element{"shape", none}, "a"},
+				identifiedElement{element{"shape", none}, "b"},
+			}
+		})
+	)
+
+	for _, test := range []struct {
+		dsl      string
+		root     s.Element
+		expected []string
+	}{
+		{"/event/colour | /event/shape", root, []string{"colour", "shape"}},
+		{"/event/shape | /event/colour", root, []string{"colour", "shape"}},
+		{"/event/shape | /event | /event/colour", root, []string{"event", "colour", "shape"}},
+		{"/event/colour | //shape | //colour", root, []string{"colour", "shape"}},
+		{"/event | /event", values, []string{"event"}},
+		{"/* | /event", values, []string{"event", "shape", "shape"}},
+		{"/shape | /shape", values, []string{"shape", "shape"}},
+	} {
+		var (
+			lex       = NewPathLexer(test.dsl).With(s.PathTokenTypes())
+			parser    = NewPathParser(lex.Iter())
+			expr, err = parser.ParseExpression()
+		)
+		if err != nil {
+			t.Fatalf("%s: %v", test.dsl, err)
+		}
+
+		res, err := NewPath(expr).Execute(test.root)
+		if err != nil {
+			t.Fatalf("%s: %v", test.dsl, err)
+		}
+
+		var names []string
+		for _, v := range res {
+			names = append(names, v.Name())
+		}
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.dsl, test.expected, names)
+		}
+	}
+}
+
+func MakeSections() s.Element {
+	child := func(name, id string) s.Element {
+		return MakeElementWithAttributes(name, map[string]interface{}{"Id": id})
+	}
+	return MakeElement("root", func() []s.Element {
+		return []s.Element{
+			MakeElementWithAttributesAndChildren("section", map[string]interface{}{"Id": "a"}, func() []s.Element {
+				return []s.Element{child("title", "a1"), child("para", "a2"), child("para", "a3"), child("note", "a4")}
+			}),
+			MakeElementWithAttributesAndChildren("section", map[string]interface{}{"Id": "b"}, func() []s.Element {
+				return []s.Element{child("title", "b1"), child("para", "b2")}
+			}),
+		}
+	})
+}
+
+func Test_PathExecuteValuesWithTrackedParents(t *testing.T) {
+	for dsl, expected := range map[string][]interface{}{
+		"/section/para/../@Id":                              []interface{}{"a", "b"},
+		"//note/ancestor::section/@Id":                      []interface{}{"a"},
+		"/section/title/following-sibling::para/@Id":        []interface{}{"a2", "a3", "b2"},
+		"/section/note/preceding-sibling::*/@Id":            []interface{}{"a3", "a2", "a1"},
+		"/section/note/preceding-sibling::*[0]/@Id":         []interface{}{"a3"},
+		"/section/para/ancestor-or-self::*/@Id":             []interface{}{"a2", "a", "a3", "b2", "b"},
+		"/section/./title/@Id":                              []interface{}{"a1", "b1"},
+		"/section.(@Id==\"b\")/para/../title/@Id":           []interface{}{"b1"},
+		"/section/para.(../note)/@Id":                       []interface{}{"a2", "a3"},
+		"/section/para.(count(preceding-sibling::*)>1)/@Id": []interface{}{"a3"},
+	} {
+		var (
+			lex       = NewPathLexer(dsl).With(s.PathTokenTypes())
+			parser    = NewPathParser(lex.Iter())
+			expr, err = parser.ParseExpression()
+		)
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+
+		res, err := NewPath(expr).ExecuteValues(MakeSections())
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+
+		if values := res.Values(); !reflect.DeepEqual(values, expected) {
+			t.Errorf("%s: expected %v, got %v", dsl, expected, values)
+		}
+	}
+}
+
+type parentElement struct {
+	pointerElement
+	parent s.Element
+}
+
+func (e *parentElement) Parent() s.Element {
+	return e.parent
+}
+
+func Test_PathExecuteWithParentElement(t *testing.T) {
+	var (
+		elements = make(map[string]*parentElement)
+		add      = func(name string, parent *parentElement) *parentElement {
+			res := &parentElement{pointerElement: pointerElement{name: name}}
+			if parent != nil {
+				res.parent = parent
+				parent.children = append(parent.children, res)
+			}
+			elements[name] = res
+			return res
+		}
+		root = add("root", nil)
+		a    = add("a", root)
+	)
+	add("b", root)
+	add("c", root)
+	add("d", a)
+
+	for _, test := range []struct {
+		dsl      string
+		element  string
+		expected []string
+	}{
+		{"..", "a", []string{"root"}},
+		{"..", "root", nil},
+		{"ancestor::*", "d", []string{"a", "root"}},
+		{"following-sibling::*", "a", []string{"b", "c"}},
+		{"preceding-sibling::*", "c", []string{"b", "a"}},
+		{"../following-sibling::c", "d", []string{"c"}},
+	} {
+		var (
+			lex       = NewPathLexer(test.dsl).With(s.PathTokenTypes())
+			parser    = NewPathParser(lex.Iter())
+			expr, err = parser.ParseExpression()
+		)
+		if err != nil {
+			t.Fatalf("%s: %v", test.dsl, err)
+		}
+
+		res, err := NewPath(expr).Execute(elements[test.element])
+		if err != nil {
+			t.Fatalf("%s: %v", test.dsl, err)
+		}
+
+		var names []string
+		for _, v := range res {
+			names = append(names, v.Name())
+		}
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("%s from %s: expected %v, got %v", test.dsl, test.element, test.expected, names)
+		}
+	}
+}
+
+// MakeInfiniteElement creates a tree with no end, counting every time the
+// children of a node are requested.
+func MakeInfiniteElement(name string, calls *int) s.Element {
+	return MakeElement(name, func() []s.Element {
+		*calls++
+		return []s.Element{
+			MakeInfiniteElement("node", calls),
+			MakeInfiniteElement("leaf", calls),
+		}
+	})
+}
+
+func Test_PathIterStopsTraversalEarly(t *testing.T) {
+	for dsl, expected := range map[string][]string{
+		"//node":        []string{"node", "node", "node"},
+		"//leaf":        []string{"leaf", "leaf", "leaf"},
+		"/node/leaf":    []string{"leaf"},
+		"//node[1]":     []string{"node"},
+		"/node | /leaf": []string{"node", "leaf"},
+	} {
+		var (
+			lex       = NewPathLexer(dsl).With(s.PathTokenTypes())
+			parser    = NewPathParser(lex.Iter())
+			expr, err = parser.ParseExpression()
+		)
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+
+		var (
+			calls int
+			names []string
+		)
+		for iter := NewPath(expr).Iter(MakeInfiniteElement("root", &calls)); iter.HasNext() && len(names) < len(expected); {
+			res, err := iter.Next()
+			if err != nil {
+				t.Fatalf("%s: %v", dsl, err)
+			}
+			names = append(names, res.Name())
+		}
+
+		if !reflect.DeepEqual(names, expected) {
+			t.Errorf("%s: expected %v, got %v", dsl, expected, names)
+		}
+		if calls > len(expected)+1 {
+			t.Errorf("%s: expected at most %d calls to children, got %d", dsl, len(expected)+1, calls)
+		}
+	}
+}
+
+func Test_PathIterWithError(t *testing.T) {
+	var (
+		lex       = NewPathLexer("/event.(unknown(@Title))").With(s.PathTokenTypes())
+		parser    = NewPathParser(lex.Iter())
+		expr, err = parser.ParseExpression()
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iter := NewPath(expr).Iter(MakeEvents())
+	if !iter.HasNext() {
+		t.Fatal("expected the error to be pending")
+	}
+	if _, err := iter.Next(); err != ErrUnknownFunction {
+		t.Errorf("expected %v, got %v", ErrUnknownFunction, err)
+	}
+	if iter.HasNext() {
+		t.Error("expected the iterator to be exhausted")
+	}
+	if _, err := iter.Next(); err != ErrIteratorExhausted {
+		t.Errorf("expected %v, got %v", ErrIteratorExhausted, err)
+	}
+}
+
+func Test_PathExecuteContextWithLimits(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	deadline, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	for _, test := range []struct {
+		dsl      string
+		ctx      context.Context
+		options  PathOptions
+		expected error
+	}{
+		{"//leaf", cancelled, PathOptions{}, context.Canceled},
+		{"//leaf", deadline, PathOptions{}, context.DeadlineExceeded},
+		{"//leaf", context.Background(), PathOptions{MaxNodes: 100}, ErrNodeLimitExceeded},
+		{"//leaf", context.Background(), PathOptions{MaxDepth: 5}, ErrDepthLimitExceeded},
+		{"/node/node/leaf", context.Background(), PathOptions{MaxDepth: 1}, nil},
+		{"/node | /leaf", context.Background(), PathOptions{MaxResults: 1}, ErrResultLimitExceeded},
+		{"/node | /leaf", context.Background(), PathOptions{MaxResults: 2, MaxNodes: 4}, nil},
+	} {
+		var (
+			lex       = NewPathLexer(test.dsl).With(s.PathTokenTypes())
+			parser    = NewPathParser(lex.Iter())
+			expr, err = parser.ParseExpression()
+		)
+		if err != nil {
+			t.Fatalf("%s: %v", test.dsl, err)
+		}
+
+		var calls int
+		if _, err := NewPath(expr).ExecuteContext(test.ctx, MakeInfiniteElement("root", &calls), test.options); err != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.dsl, test.expected, err)
+		}
+	}
+}
+
+func Test_PathExecuteWithEvaluator(t *testing.T) {
+	var (
+		lex       = NewPathLexer("/event.(#col)/#col").With(s.PathTokenTypes()).WithRune('#', pttHash)
+		config    = DefaultParserConfig().WithPrefix(pttHash, pathHash{})
+		expr, err = NewPathParserWith(lex.Iter(), config).ParseExpression()
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewPath(expr).Execute(MakeColouredEvents()); err != ErrUnexpectedExpression {
+		t.Errorf("expected %v, got %v", ErrUnexpectedExpression, err)
+	}
+
+	path := NewPath(expr).WithEvaluator(petHash, PathEvaluator{
+		Select: func(expr s.PathExpression, element s.Element) ([]s.Element, error) {
+			var res []s.Element
+			for _, v := range element.Children() {
+				if strings.HasPrefix(v.Name(), expr.(hashType).prefix) {
+					res = append(res, v)
+				}
+			}
+			return res, nil
+		},
+	})
+
+	res, err := path.Execute(MakeColouredEvents())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Errorf("expected 2 colours, got %v", res)
+	}
+	for _, v := range res {
+		if v.Name() != "colour" {
+			t.Errorf("expected colour, got %s", v.Name())
+		}
+	}
+}
+
+func Test_PathExecuteWithQuotedNames(t *testing.T) {
+	root := MakeElement("root", func() []s.Element {
+		return []s.Element{
+			MakeElement("weird name", func() []s.Element {
+				return MakeElements("child", 2)
+			}),
+			MakeElement("größe", func() []s.Element {
+				return MakeElements("xml:lang", 3)
+			}),
+		}
+	})
+
+	for dsl, expected := range map[string]int{
+		`/"weird name"/child`:       2,
+		`/'weird name'[0]/child[1]`: 1,
+		`/größe/"xml:lang"`:         3,
+		`//"xml:lang"`:              3,
+		`/größe/child::'xml:lang'`:  3,
+		`/"weird name"/"child"`:     2,
+		`/größe/xml:lang`:           3,
+	} {
+		lex := NewPathLexer(dsl).With(s.PathTokenTypes())
+		if dsl == `/größe/xml:lang` {
+			lex = lex.WithNameRunes(':')
+		}
+
+		expr, err := NewPathParser(lex.Iter()).ParseExpression()
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+
+		res, err := NewPath(expr).Execute(root)
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+		if len(res) != expected {
+			t.Errorf("%s: expected %d, got %d", dsl, expected, len(res))
+		}
+	}
+
+	// Quoted names are described with their quotes.
+	var (
+		lex     = NewPathLexer(`/"weird name"/größe`).With(s.PathTokenTypes())
+		expr, _ = NewPathParser(lex.Iter()).ParseExpression()
+
+		buffer = new(bytes.Buffer)
+		writer = bufio.NewWriter(buffer)
+	)
+	NewPath(expr).Describe(writer)
+	writer.Flush()
+
+	if res := buffer.String(); res != `(/"weird name"(größe))` {
+		t.Errorf("expected %q, got %q", `(/"weird name"(größe))`, res)
+	}
+}
+
+func Test_PathExecuteDescendantsWithinPath(t *testing.T) {
+	root := MakeElement("root", func() []s.Element {
+		return []s.Element{
+			MakeElement("a", func() []s.Element {
+				return []s.Element{
+					MakeElement("b", func() []s.Element {
+						return MakeElements("c", 2)
+					}),
+					MakeElement("c", func() []s.Element {
+						return []s.Element{}
+					}),
+				}
+			}),
+			MakeElement("c", func() []s.Element {
+				return []s.Element{}
+			}),
+		}
+	})
+
+	for dsl, expected := range map[string]int{
+		`/a//c`:   3,
+		`/a/c`:    1,
+		`//a//c`:  3,
+		`/a//b/c`: 2,
+		`/*//c`:   3,
+	} {
+		lex := NewPathLexer(dsl).With(s.PathTokenTypes())
+		expr, err := NewPathParser(lex.Iter()).ParseExpression()
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+
+		res, err := NewPath(expr).Execute(root)
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+		if len(res) != expected {
+			t.Errorf("%s: expected %d, got %d", dsl, expected, len(res))
+		}
+	}
+}
+
+func Test_PathExecuteWithPredicateAndNonLiteralValues(t *testing.T) {
+	predicate := PathPredicate{
+		Equality: func(element s.Element, name string, value interface{}) bool {
+			res, ok := element.(s.Attributes).Attribute(name)
+			return ok && strings.EqualFold(toString(res), toString(value))
+		},
+	}
+	root := MakeElement("root", func() []s.Element {
+		return []s.Element{
+			MakeElementWithAttributes("event", map[string]interface{}{"State": "open", "Next": "closed"}),
+			MakeElementWithAttributes("event", map[string]interface{}{"State": "closed", "Next": "closed"}),
+		}
+	})
+
+	for dsl, expected := range map[string]int{
+		`/event.(@State==@State)`:          2,
+		`/event.(@State==@Next)`:           1,
+		`/event.(@State!=@Next)`:           1,
+		`/event.(@State==lower("OPEN"))`:   1,
+		`/event.(@State==upper("closed"))`: 0,
+	} {
+		var (
+			lex       = NewPathLexer(dsl).With(s.PathTokenTypes())
+			parser    = NewPathParser(lex.Iter())
+			expr, err = parser.ParseExpression()
+		)
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+
+		res, err := NewPath(expr).With(predicate).Execute(root)
+		if err != nil {
+			t.Fatalf("%s: %v", dsl, err)
+		}
+		if len(res) != expected {
+			t.Errorf("%s: expected %d, got %d", dsl, expected, len(res))
+		}
+	}
+}
@@ -0,0 +1,417 @@
+// Package reflectelements exposes Go values as elements using reflection, so
+// that they can be queried with a path.
+package reflectelements
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+// ValueAttribute is the attribute holding the value of a scalar found within
+// a slice or array, as it has no name of its own.
+const ValueAttribute = "value"
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// element wraps a value with the pointers and interfaces leading to it
+// removed. Structs have a child for every field holding a struct or map and
+// for every item of a slice or array held by a field, named after the field,
+// and an attribute for every field holding a scalar. Maps are the same, named
+// by their keys.
+type element struct {
+	name    string
+	value   reflect.Value
+	address uintptr
+	pointer string
+	parent  *element
+}
+
+// MakeElement wraps the value as the root element. The items of a root slice
+// are named after the root.
+func MakeElement(name string, value interface{}) s.Element {
+	v, address := indirect(reflect.ValueOf(value))
+	return &element{
+		name:    name,
+		value:   v,
+		address: address,
+	}
+}
+
+func (e *element) Name() string {
+	return e.name
+}
+
+// ID is the JSON pointer like path of the value from the root.
+func (e *element) ID() string {
+	return e.pointer
+}
+
+func (e *element) Parent() s.Element {
+	if e.parent == nil {
+		return nil
+	}
+	return e.parent
+}
+
+// Value returns the value the element wraps, or nil if it can't be.
+func (e *element) Value() interface{} {
+	if e.value.IsValid() && e.value.CanInterface() {
+		return e.value.Interface()
+	}
+	return nil
+}
+
+func (e *element) Children() []s.Element {
+	res := make([]s.Element, 0)
+	if _, ok := scalar(e.value); ok {
+		return res
+	}
+
+	switch e.value.Kind() {
+	case reflect.Struct:
+		for _, f := range fields(e.value.Type()) {
+			if v, ok := f.value(e.value); ok {
+				res = e.append(res, f.name, v)
+			}
+		}
+	case reflect.Map:
+		for _, k := range keys(e.value) {
+			res = e.append(res, k.name, e.value.MapIndex(k.key))
+		}
+	case reflect.Slice, reflect.Array:
+		res = append(res, e.items(e.name, e.value, e)...)
+	}
+	return res
+}
+
+// append adds the children for the named value, skipping any value that's
+// already an ancestor, so that cycles end.
+func (e *element) append(res []s.Element, name string, value reflect.Value) []s.Element {
+	v, address := indirect(value)
+	if _, ok := scalar(v); ok || e.cycle(v, address) {
+		return res
+	}
+
+	child := e.child(name, name, v, address)
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map:
+		return append(res, child)
+	case reflect.Slice, reflect.Array:
+		return append(res, e.items(name, v, child)...)
+	}
+	return res
+}
+
+// items creates a child for every item of the slice or array, the container
+// holds the pointer of the slice or array.
+func (e *element) items(name string, value reflect.Value, container *element) []s.Element {
+	res := make([]s.Element, 0, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		v, address := indirect(value.Index(i))
+		if e.cycle(v, address) {
+			continue
+		}
+		child := container.child(name, strconv.Itoa(i), v, address)
+		child.parent = e
+		res = append(res, child)
+	}
+	return res
+}
+
+func (e *element) child(name, key string, value reflect.Value, address uintptr) *element {
+	return &element{
+		name:    name,
+		value:   value,
+		address: address,
+		pointer: e.pointer + "/" + pointerEscaper.Replace(key),
+		parent:  e,
+	}
+}
+
+func (e *element) cycle(value reflect.Value, address uintptr) bool {
+	if address == 0 {
+		return false
+	}
+	for x := e; x != nil; x = x.parent {
+		if x.address == address && x.value.Type() == value.Type() {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *element) Attribute(name string) (interface{}, bool) {
+	if res, ok := scalar(e.value); ok {
+		if name == ValueAttribute {
+			return res, true
+		}
+		return nil, false
+	}
+
+	var value reflect.Value
+	switch e.value.Kind() {
+	case reflect.Struct:
+		f, ok := field(e.value.Type(), name)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = f.value(e.value); !ok {
+			return nil, false
+		}
+	case reflect.Map:
+		if value = mapIndex(e.value, name); !value.IsValid() {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	return attribute(value)
+}
+
+func (e *element) Attributes() []string {
+	if _, ok := scalar(e.value); ok {
+		return []string{ValueAttribute}
+	}
+
+	res := make([]string, 0)
+	switch e.value.Kind() {
+	case reflect.Struct:
+		for _, f := range fields(e.value.Type()) {
+			if v, ok := f.value(e.value); ok {
+				if _, ok := attribute(v); ok {
+					res = append(res, f.name)
+				}
+			}
+		}
+	case reflect.Map:
+		for _, k := range keys(e.value) {
+			if _, ok := attribute(e.value.MapIndex(k.key)); ok {
+				res = append(res, k.name)
+			}
+		}
+	}
+	return res
+}
+
+// indirect follows pointers and interfaces, returning the address of the last
+// pointer or of the map, which identifies the value when looking for cycles.
+// Nil pointers, interfaces and maps give the zero value.
+func indirect(value reflect.Value) (reflect.Value, uintptr) {
+	var address uintptr
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return reflect.Value{}, 0
+		}
+		if value.Kind() == reflect.Ptr {
+			address = value.Pointer()
+		}
+		value = value.Elem()
+	}
+	if value.Kind() == reflect.Map {
+		if value.IsNil() {
+			return reflect.Value{}, 0
+		}
+		address = value.Pointer()
+	}
+	return value, address
+}
+
+// scalar returns the value of anything that's not a struct, map, slice or
+// array, or marshals to text, such as time.Time. Nil is a scalar, channels and
+// functions are not.
+func scalar(value reflect.Value) (interface{}, bool) {
+	if !value.IsValid() {
+		return nil, true
+	}
+	if value.CanInterface() {
+		if x, ok := value.Interface().(encoding.TextMarshaler); ok {
+			if text, err := x.MarshalText(); err == nil {
+				return string(text), true
+			}
+		}
+	}
+
+	switch value.Kind() {
+	case reflect.Bool:
+		return value.Bool(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return value.Uint(), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	case reflect.String:
+		return value.String(), true
+	}
+	return nil, false
+}
+
+var textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// attribute returns the scalar held by the value. Nil pointers to structs and
+// nil maps are not attributes, as they're children when they're set.
+func attribute(value reflect.Value) (interface{}, bool) {
+	v, _ := indirect(value)
+	if !v.IsValid() && composite(value.Type()) {
+		return nil, false
+	}
+	return scalar(v)
+}
+
+// composite reports if the type, past any pointers, is a struct, map, slice or
+// array that doesn't marshal to text.
+func composite(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Implements(textMarshaler) || reflect.PtrTo(t).Implements(textMarshaler) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return true
+	}
+	return false
+}
+
+type mapKey struct {
+	name string
+	key  reflect.Value
+}
+
+// keys returns the keys of the map sorted by their names.
+func keys(value reflect.Value) []mapKey {
+	res := make([]mapKey, 0, value.Len())
+	for _, k := range value.MapKeys() {
+		res = append(res, mapKey{keyName(k), k})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].name < res[j].name
+	})
+	return res
+}
+
+func keyName(key reflect.Value) string {
+	if x, ok := scalar(key); ok {
+		return fmt.Sprint(x)
+	} else if key.CanInterface() {
+		return fmt.Sprint(key.Interface())
+	}
+	return ""
+}
+
+// mapIndex returns the value of the map with the named key, which is looked up
+// directly when the keys are strings.
+func mapIndex(value reflect.Value, name string) reflect.Value {
+	if t := value.Type().Key(); t.Kind() == reflect.String && !t.Implements(textMarshaler) {
+		return value.MapIndex(reflect.ValueOf(name).Convert(t))
+	}
+	for _, k := range value.MapKeys() {
+		if keyName(k) == name {
+			return value.MapIndex(k)
+		}
+	}
+	return reflect.Value{}
+}
+
+type structField struct {
+	name  string
+	index []int
+}
+
+// value returns the field of the struct, following embedded pointers, which
+// fails if any of them are nil.
+func (f structField) value(value reflect.Value) (reflect.Value, bool) {
+	for k, i := range f.index {
+		if k > 0 && value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return reflect.Value{}, false
+			}
+			value = value.Elem()
+		}
+		value = value.Field(i)
+	}
+	return value, true
+}
+
+var cache sync.Map
+
+// fields returns the exported fields of the struct type, named by their json
+// tags when they have one. Fields of embedded structs are promoted unless the
+// name is already taken. The fields are cached for each type.
+func fields(t reflect.Type) []structField {
+	if res, ok := cache.Load(t); ok {
+		return res.([]structField)
+	}
+	res := typeFields(t, nil, map[reflect.Type]bool{})
+	cache.Store(t, res)
+	return res
+}
+
+func field(t reflect.Type, name string) (structField, bool) {
+	for _, v := range fields(t) {
+		if v.name == name {
+			return v, true
+		}
+	}
+	return structField{}, false
+}
+
+func typeFields(t reflect.Type, index []int, visited map[reflect.Type]bool) []structField {
+	if visited[t] {
+		return nil
+	}
+	visited[t] = true
+	defer delete(visited, t)
+
+	var res, embedded []structField
+	for i := 0; i < t.NumField(); i++ {
+		var (
+			f    = t.Field(i)
+			tag  = f.Tag.Get("json")
+			name = strings.Split(tag, ",")[0]
+		)
+		if tag == "-" {
+			continue
+		}
+
+		fieldIndex := append(append([]int{}, index...), i)
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, typeFields(ft, fieldIndex, visited)...)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		res = append(res, structField{name, fieldIndex})
+	}
+
+	for _, v := range embedded {
+		taken := false
+		for _, x := range res {
+			if taken = x.name == v.name; taken {
+				break
+			}
+		}
+		if !taken {
+			res = append(res, v)
+		}
+	}
+	return res
+}
@@ -0,0 +1,143 @@
+package reflectelements_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SimonRichardson/cilli"
+	"github.com/SimonRichardson/cilli/reflectelements"
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+type colour struct {
+	Red   uint8 `json:"red"`
+	Green uint8 `json:"-"`
+}
+
+type base struct {
+	ID   int
+	Name string
+}
+
+type event struct {
+	base
+	Name    string    `json:"name,omitempty"`
+	Date    time.Time `json:"date"`
+	Colours []colour  `json:"colour"`
+	Tags    []string
+	Labels  map[string]interface{}
+	Next    *event
+	Parent  *event
+	private string
+}
+
+func Test_ReflectElements(t *testing.T) {
+	var (
+		date  = time.Date(2017, 3, 10, 23, 0, 0, 0, time.UTC)
+		first = &event{
+			base:    base{ID: 1, Name: "base"},
+			Name:    "launch",
+			Date:    date,
+			Colours: []colour{{Red: 20}, {Red: 10}},
+			Tags:    []string{"a", "b"},
+			Labels:  map[string]interface{}{"level": 2, "nested": map[string]uint{"x": 1}, "none": nil},
+			private: "hidden",
+		}
+		second = &event{
+			base:   base{ID: 2},
+			Date:   date.AddDate(1, 0, 0),
+			Parent: first,
+		}
+	)
+	// A cycle, first is reachable from second and second from first.
+	first.Next = second
+
+	root := reflectelements.MakeElement("events", []*event{first, second})
+	for _, test := range []struct {
+		dsl    string
+		ids    []string
+		values []interface{}
+	}{
+		{
+			dsl: `/events.(@date=="2017-03-10T23:00:00Z")/colour.(@red==20)`,
+			ids: []string{"/0/colour/0"},
+		},
+		{
+			dsl:    `/events[0]/@*`,
+			values: []interface{}{"launch", "2017-03-10T23:00:00Z", int64(1), "base"},
+		},
+		{
+			dsl:    `/events[0]/Tags/@value`,
+			values: []interface{}{"a", "b"},
+		},
+		{
+			dsl:    `/events[0]/Labels/@level`,
+			values: []interface{}{int64(2)},
+		},
+		{
+			dsl:    `/events[0]/Labels/@*`,
+			values: []interface{}{int64(2), nil},
+		},
+		{
+			dsl:    `/events[0]/Labels/nested/@x`,
+			values: []interface{}{uint64(1)},
+		},
+		{
+			dsl: `/events/Next/Parent`,
+			ids: nil,
+		},
+		{
+			dsl: `/events/Next`,
+			ids: []string{"/0/Next"},
+		},
+		{
+			dsl: `/events/Parent`,
+			ids: []string{"/1/Parent"},
+		},
+		{
+			dsl: `//colour`,
+			ids: []string{"/0/colour/0", "/0/colour/1", "/1/Parent/colour/0", "/1/Parent/colour/1"},
+		},
+	} {
+		lex := cilli.NewPathLexer(test.dsl).With(s.PathTokenTypes())
+		expr, err := cilli.NewPathParser(lex.Iter()).ParseExpression()
+		if err != nil {
+			t.Fatalf("%s: %v", test.dsl, err)
+		}
+		res, err := cilli.NewPath(expr).ExecuteValues(root)
+		if err != nil {
+			t.Fatalf("%s: %v", test.dsl, err)
+		}
+		var ids []string
+		for _, v := range res.Elements() {
+			ids = append(ids, v.(s.Identifier).ID())
+		}
+		if !reflect.DeepEqual(ids, test.ids) {
+			t.Errorf("%s: expected %v, got %v", test.dsl, test.ids, ids)
+		}
+		if test.values != nil && !reflect.DeepEqual(res.Values(), test.values) {
+			t.Errorf("%s: expected %v, got %v", test.dsl, test.values, res.Values())
+		}
+	}
+}
+
+func Test_ReflectElementsMapKeys(t *testing.T) {
+	type key string
+
+	for _, test := range []struct {
+		value interface{}
+		name  string
+	}{
+		{map[key]int{"a": 1, "b": 2}, "b"},
+		{map[int]int{1: 1, 2: 2}, "2"},
+	} {
+		root := reflectelements.MakeElement("root", test.value).(s.Attributes)
+		if value, ok := root.Attribute(test.name); !ok || value != int64(2) {
+			t.Errorf("%T: expected 2, got %v", test.value, value)
+		}
+		if value, ok := root.Attribute("c"); ok {
+			t.Errorf("%T: expected no value, got %v", test.value, value)
+		}
+	}
+}
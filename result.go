@@ -0,0 +1,188 @@
+package cilli
+
+import (
+	"reflect"
+
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+// PathResult is a single item selected by a path, it's either an element or
+// an attribute value projected from an element.
+type PathResult struct {
+	element   s.Element
+	node      *pathNode
+	name      string
+	value     interface{}
+	attribute bool
+}
+
+func MakePathElementResult(element s.Element) PathResult {
+	return PathResult{
+		element: element,
+	}
+}
+
+func MakePathAttributeResult(element s.Element, name string, value interface{}) PathResult {
+	return PathResult{
+		element:   element,
+		name:      name,
+		value:     value,
+		attribute: true,
+	}
+}
+
+// Element returns the selected element, or the element the attribute was
+// projected from.
+func (p PathResult) Element() s.Element {
+	return p.element
+}
+
+func (p PathResult) IsAttribute() bool {
+	return p.attribute
+}
+
+// Name returns the name of the attribute, or the name of the element.
+func (p PathResult) Name() string {
+	if p.attribute {
+		return p.name
+	}
+	return p.element.Name()
+}
+
+// Value returns the attribute value, or the element itself.
+func (p PathResult) Value() interface{} {
+	if p.attribute {
+		return p.value
+	}
+	return p.element
+}
+
+// PathResultIterator pulls the results of a path one at a time, traversing only
+// as much of the tree as is needed for each result.
+type PathResultIterator interface {
+	HasNext() bool
+	Next() (PathResult, error)
+}
+
+type PathResults []PathResult
+
+func (p PathResults) Elements() []s.Element {
+	var res []s.Element
+	for _, v := range p {
+		if !v.attribute {
+			res = append(res, v.element)
+		}
+	}
+	return res
+}
+
+func (p PathResults) Values() []interface{} {
+	res := make([]interface{}, len(p))
+	for k, v := range p {
+		res[k] = v.Value()
+	}
+	return res
+}
+
+func elements(nodes nodeStream) resultStream {
+	return func() (PathResult, bool, error) {
+		node, ok, err := nodes()
+		if err != nil || !ok {
+			return PathResult{}, false, err
+		}
+		res := MakePathElementResult(node.element)
+		res.node = node
+		return res, true, nil
+	}
+}
+
+// union merges both results in document order, keeping the first occurrence
+// of any duplicates. See pathNode.key for how elements are considered the
+// same.
+// Results whose order isn't known, such as ones from elements above where the
+// path started, take the left side first.
+func union(a, b resultStream) resultStream {
+	type key struct {
+		element interface{}
+		name    string
+	}
+
+	var (
+		seen    = make(map[key]struct{})
+		streams = []resultStream{a, b}
+		heads   = make([]*PathResult, 2)
+	)
+	return func() (PathResult, bool, error) {
+		for {
+			for k, stream := range streams {
+				if heads[k] != nil || stream == nil {
+					continue
+				}
+				res, ok, err := stream()
+				if err != nil {
+					return PathResult{}, false, err
+				}
+				if !ok {
+					streams[k] = nil
+					continue
+				}
+				heads[k] = &res
+			}
+
+			next := 0
+			if heads[0] == nil || (heads[1] != nil && heads[1].precedes(*heads[0])) {
+				next = 1
+			}
+			if heads[next] == nil {
+				return PathResult{}, false, nil
+			}
+			res := *heads[next]
+			heads[next] = nil
+
+			if id, ok := res.key(); ok {
+				k := key{id, res.name}
+				if _, ok := seen[k]; ok {
+					continue
+				}
+				seen[k] = struct{}{}
+			}
+			return res, true, nil
+		}
+	}
+}
+
+// precedes reports if the result was reached before the other in document
+// order, which is only known for results from the same run.
+func (p PathResult) precedes(other PathResult) bool {
+	if p.node == nil || other.node == nil {
+		return false
+	}
+	return p.node.precedes(other.node)
+}
+
+// key returns a comparable key for the element of the result, see
+// pathNode.key. Results without a node fall back to the identity of the
+// element.
+func (p PathResult) key() (interface{}, bool) {
+	if p.node != nil {
+		return p.node.key(), true
+	}
+	return identity(p.element)
+}
+
+type identifier struct {
+	id string
+}
+
+// identity returns a comparable key for the element. Elements implementing
+// s.Identifier are identified by their ID, otherwise pointers are compared.
+// Any other element has no identity of its own.
+func identity(element s.Element) (interface{}, bool) {
+	if x, ok := element.(s.Identifier); ok {
+		return identifier{x.ID()}, true
+	}
+	if element != nil && reflect.TypeOf(element).Kind() == reflect.Ptr {
+		return element, true
+	}
+	return nil, false
+}
@@ -9,6 +9,20 @@ type Branch interface {
 	Right() PathExpression
 }
 
+type Unary interface {
+	Operand() PathExpression
+}
+
 type List interface {
 	List() []PathExpression
 }
+
+type MethodCall interface {
+	Method() PathExpression
+	Parameters() []PathExpression
+}
+
+type Axis interface {
+	Axis() PathAxisType
+	Test() PathExpression
+}
@@ -4,3 +4,25 @@ type Element interface {
 	Name() string
 	Children() []Element
 }
+
+// Attributes can optionally be implemented by an Element to expose its
+// attribute values to a path.
+type Attributes interface {
+	Attribute(string) (interface{}, bool)
+	Attributes() []string
+}
+
+// Identifier can optionally be implemented by an Element so that the same
+// element reached through different paths can be recognised. Elements that
+// don't implement it are compared by pointer, other elements are the same when
+// they're at the same position below where the path started.
+type Identifier interface {
+	ID() string
+}
+
+// ParentElement can optionally be implemented by an Element to expose its
+// parent. Elements reached by walking down from where the path started have
+// their parents tracked regardless, the interface allows navigating above it.
+type ParentElement interface {
+	Parent() Element
+}
@@ -28,6 +28,12 @@ const (
 	PETLessThanOrEqualTo
 	PETGreaterThan
 	PETGreaterThanOrEqualTo
+	PETLogicalNot
+	PETSlice
+	PETIndexList
+	PETUnion
+	PETAxis
+	PETError
 )
 
 func (p PathExpressionType) String() string {
@@ -67,7 +73,7 @@ func (p PathExpressionType) String() string {
 	case PETEquality:
 		return "Equality"
 	case PETInequality:
-		return "Inquality"
+		return "Inequality"
 	case PETLogicalAnd:
 		return "LogicalAnd"
 	case PETLogicalOr:
@@ -82,10 +88,58 @@ func (p PathExpressionType) String() string {
 		return "GreaterThan"
 	case PETGreaterThanOrEqualTo:
 		return "GreaterThanOrEqualTo"
+	case PETLogicalNot:
+		return "LogicalNot"
+	case PETSlice:
+		return "Slice"
+	case PETIndexList:
+		return "IndexList"
+	case PETUnion:
+		return "Union"
+	case PETAxis:
+		return "Axis"
+	case PETError:
+		return "Error"
 	}
 	return ""
 }
 
+func PathExpressionTypes() []PathExpressionType {
+	return []PathExpressionType{
+		PETWildcard,
+		PETAllDescendants,
+		PETDescendants,
+		PETNameDescendants,
+		PETbranch,
+		PETString,
+		PETName,
+		PETIndexAccess,
+		PETNumber,
+		PETInteger,
+		PETInfixAttribute,
+		PETMethodCall,
+		PETGroup,
+		PETInstance,
+		PETIndexAccessDescendants,
+		PETAttribute,
+		PETEquality,
+		PETInequality,
+		PETLogicalAnd,
+		PETLogicalOr,
+		PETBoolean,
+		PETLessThan,
+		PETLessThanOrEqualTo,
+		PETGreaterThan,
+		PETGreaterThanOrEqualTo,
+		PETLogicalNot,
+		PETSlice,
+		PETIndexList,
+		PETUnion,
+		PETAxis,
+		PETError,
+	}
+}
+
 type PathExpression interface {
 	Type() PathExpressionType
 }
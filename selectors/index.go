@@ -3,3 +3,9 @@ package selectors
 type Index interface {
 	Index() int
 }
+
+type Slice interface {
+	Start() PathExpression
+	End() PathExpression
+	Step() PathExpression
+}
@@ -8,3 +8,18 @@ type PathParser interface {
 	Consume() (PathToken, error)
 	ConsumeToken(PathTokenType) (PathToken, error)
 }
+
+// PathScanner can optionally be implemented by a PathParser to look at the
+// next token without consuming it. Without it, slices have to give every
+// index, keywords can't be used as names and running out of input isn't
+// reported against the token that was expected.
+type PathScanner interface {
+	// ParseNameBy parses like ParseExpressionBy, except that a keyword at the
+	// start is read as a name, for steps and attributes.
+	ParseNameBy(PathPrecedence) (PathExpression, error)
+
+	// More reports if there are any tokens left to parse.
+	More() bool
+	// Check reports if the next token is of the type, without consuming it.
+	Check(PathTokenType) bool
+}
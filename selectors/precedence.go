@@ -2,8 +2,14 @@ package selectors
 
 type PathPrecedence int
 
+// The precedences are spaced apart so that custom parselets can sit between
+// any of them.
 const (
-	PPEquality PathPrecedence = iota
+	PPEquality PathPrecedence = iota * 10
+	PPUnion
+	PPLogicalOr
+	PPLogicalAnd
+	PPComparison
 	PPConditional
 	PPSum
 	PPProduct
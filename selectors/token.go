@@ -9,6 +9,58 @@ const (
 	PDTContext
 )
 
+type PathAxisType int
+
+const (
+	PAXSelf PathAxisType = iota
+	PAXChild
+	PAXDescendant
+	PAXDescendantOrSelf
+	PAXParent
+	PAXAncestor
+	PAXAncestorOrSelf
+	PAXFollowingSibling
+	PAXPrecedingSibling
+)
+
+func (p PathAxisType) String() string {
+	switch p {
+	case PAXSelf:
+		return "self"
+	case PAXChild:
+		return "child"
+	case PAXDescendant:
+		return "descendant"
+	case PAXDescendantOrSelf:
+		return "descendant-or-self"
+	case PAXParent:
+		return "parent"
+	case PAXAncestor:
+		return "ancestor"
+	case PAXAncestorOrSelf:
+		return "ancestor-or-self"
+	case PAXFollowingSibling:
+		return "following-sibling"
+	case PAXPrecedingSibling:
+		return "preceding-sibling"
+	}
+	return ""
+}
+
+func PathAxisTypes() []PathAxisType {
+	return []PathAxisType{
+		PAXSelf,
+		PAXChild,
+		PAXDescendant,
+		PAXDescendantOrSelf,
+		PAXParent,
+		PAXAncestor,
+		PAXAncestorOrSelf,
+		PAXFollowingSibling,
+		PAXPrecedingSibling,
+	}
+}
+
 type PathTokenType int
 
 const (
@@ -40,6 +92,17 @@ const (
 	PTTPipe
 	PTTForwardArrow
 	PTTBackArrow
+	PTTDoubleEquality
+	PTTBangEquality
+	PTTBackArrowEquality
+	PTTForwardArrowEquality
+	PTTDoubleAmpersand
+	PTTDoublePipe
+	PTTDoubleColon
+	PTTDoubleDot
+	PTTAnd
+	PTTOr
+	PTTNot
 )
 
 func (p PathTokenType) Rune() rune {
@@ -148,10 +211,44 @@ func (p PathTokenType) String() string {
 		return ">"
 	case PTTBackArrow:
 		return "<"
+	case PTTDoubleEquality:
+		return "=="
+	case PTTBangEquality:
+		return "!="
+	case PTTBackArrowEquality:
+		return "<="
+	case PTTForwardArrowEquality:
+		return ">="
+	case PTTDoubleAmpersand:
+		return "&&"
+	case PTTDoublePipe:
+		return "||"
+	case PTTDoubleColon:
+		return "::"
+	case PTTDoubleDot:
+		return ".."
+	case PTTAnd:
+		return "and"
+	case PTTOr:
+		return "or"
+	case PTTNot:
+		return "not"
 	}
 	return ""
 }
 
+// Operator reports if the token is made up of more than one rune, the lexer
+// matches these by their String.
+func (p PathTokenType) Operator() bool {
+	return p >= PTTDoubleEquality && p <= PTTDoubleDot
+}
+
+// Keyword reports if the token is a reserved word, which would otherwise be
+// lexed as a name.
+func (p PathTokenType) Keyword() bool {
+	return p >= PTTAnd && p <= PTTNot
+}
+
 func PathTokenTypes() []PathTokenType {
 	return []PathTokenType{
 		PTTLeftParen,
@@ -176,12 +273,36 @@ func PathTokenTypes() []PathTokenType {
 		PTTPipe,
 		PTTForwardArrow,
 		PTTBackArrow,
+		PTTDoubleEquality,
+		PTTBangEquality,
+		PTTBackArrowEquality,
+		PTTForwardArrowEquality,
+		PTTDoubleAmpersand,
+		PTTDoublePipe,
+		PTTDoubleColon,
+		PTTDoubleDot,
+		PTTAnd,
+		PTTOr,
+		PTTNot,
 	}
 }
 
+// PathPosition is a location within the source of a path. The offset is in
+// bytes, whilst the line and column start at 1 and the column counts runes.
+type PathPosition struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p PathPosition) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
 type PathToken struct {
-	tokenType PathTokenType
-	val       string
+	tokenType  PathTokenType
+	val        string
+	start, end PathPosition
 }
 
 func MakePathToken(tokenType PathTokenType, val string) PathToken {
@@ -191,6 +312,17 @@ func MakePathToken(tokenType PathTokenType, val string) PathToken {
 	}
 }
 
+// MakePathTokenAt creates a token spanning from the start up to, but not
+// including, the end position of the source.
+func MakePathTokenAt(tokenType PathTokenType, val string, start, end PathPosition) PathToken {
+	return PathToken{
+		tokenType: tokenType,
+		val:       val,
+		start:     start,
+		end:       end,
+	}
+}
+
 func (p PathToken) Type() PathTokenType {
 	return p.tokenType
 }
@@ -199,6 +331,14 @@ func (p PathToken) Val() string {
 	return p.val
 }
 
+func (p PathToken) Start() PathPosition {
+	return p.start
+}
+
+func (p PathToken) End() PathPosition {
+	return p.end
+}
+
 func (p PathToken) String() string {
 	return fmt.Sprintf("(TokenType:%s, Value:%q)", p.tokenType.String(), p.val)
 }
@@ -3,3 +3,9 @@ package selectors
 type Value interface {
 	Value() interface{}
 }
+
+// Invalid is implemented by the error nodes left in the place of anything that
+// failed to parse when recovering.
+type Invalid interface {
+	Err() error
+}
@@ -0,0 +1,195 @@
+package cilli
+
+// nodeStream pulls the next node, returning false once it has been exhausted.
+// Streams are composed when a path is run and nothing is traversed until the
+// nodes are pulled.
+type nodeStream func() (*pathNode, bool, error)
+
+func streamOf(nodes ...*pathNode) nodeStream {
+	return func() (*pathNode, bool, error) {
+		if len(nodes) == 0 {
+			return nil, false, nil
+		}
+		res := nodes[0]
+		nodes = nodes[1:]
+		return res, true, nil
+	}
+}
+
+func failed(err error) nodeStream {
+	return func() (*pathNode, bool, error) {
+		return nil, false, err
+	}
+}
+
+// deferred builds the stream on the first pull, for the steps that need every
+// node before they can select any.
+func deferred(fn func() ([]*pathNode, error)) nodeStream {
+	var stream nodeStream
+	return func() (*pathNode, bool, error) {
+		if stream == nil {
+			nodes, err := fn()
+			if err != nil {
+				return nil, false, err
+			}
+			stream = streamOf(nodes...)
+		}
+		return stream()
+	}
+}
+
+func collect(stream nodeStream) ([]*pathNode, error) {
+	var res []*pathNode
+	for {
+		node, ok, err := stream()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return res, nil
+		}
+		res = append(res, node)
+	}
+}
+
+func filter(stream nodeStream, fn func(*pathNode) (bool, error)) nodeStream {
+	return func() (*pathNode, bool, error) {
+		for {
+			node, ok, err := stream()
+			if err != nil || !ok {
+				return nil, false, err
+			}
+			match, err := fn(node)
+			if err != nil {
+				return nil, false, err
+			}
+			if match {
+				return node, true, nil
+			}
+		}
+	}
+}
+
+// expand replaces every node with the nodes of its own stream.
+func expand(stream nodeStream, fn func(*pathNode) nodeStream) nodeStream {
+	current := streamOf()
+	return func() (*pathNode, bool, error) {
+		for {
+			node, ok, err := current()
+			if err != nil {
+				return nil, false, err
+			}
+			if ok {
+				return node, true, nil
+			}
+
+			next, ok, err := stream()
+			if err != nil || !ok {
+				return nil, false, err
+			}
+			current = fn(next)
+		}
+	}
+}
+
+func concat(streams ...nodeStream) nodeStream {
+	return func() (*pathNode, bool, error) {
+		for len(streams) > 0 {
+			node, ok, err := streams[0]()
+			if err != nil || ok {
+				return node, ok, err
+			}
+			streams = streams[1:]
+		}
+		return nil, false, nil
+	}
+}
+
+// replay allows the stream to be pulled more than once, every stream returned
+// starts from the beginning and shares the nodes already pulled.
+func replay(stream nodeStream) func() nodeStream {
+	var (
+		buffer []*pathNode
+		done   bool
+	)
+	return func() nodeStream {
+		index := 0
+		return func() (*pathNode, bool, error) {
+			if index < len(buffer) {
+				index++
+				return buffer[index-1], true, nil
+			}
+			if done {
+				return nil, false, nil
+			}
+
+			node, ok, err := stream()
+			if err != nil {
+				return nil, false, err
+			}
+			if !ok {
+				done = true
+				return nil, false, nil
+			}
+			buffer = append(buffer, node)
+			index++
+			return node, true, nil
+		}
+	}
+}
+
+// resultStream pulls the next result, returning false once it has been
+// exhausted.
+type resultStream func() (PathResult, bool, error)
+
+func emptyResults() (PathResult, bool, error) {
+	return PathResult{}, false, nil
+}
+
+func collectResults(stream resultStream) (PathResults, error) {
+	var res PathResults
+	for {
+		result, ok, err := stream()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return res, nil
+		}
+		res = append(res, result)
+	}
+}
+
+type pathResultIterator struct {
+	stream resultStream
+	result PathResult
+	err    error
+	ready  bool
+	done   bool
+}
+
+func (i *pathResultIterator) HasNext() bool {
+	if !i.ready && !i.done {
+		var ok bool
+		if i.result, ok, i.err = i.stream(); i.err != nil || ok {
+			i.ready = true
+		} else {
+			i.done = true
+		}
+	}
+	return i.ready
+}
+
+func (i *pathResultIterator) Next() (PathResult, error) {
+	if !i.HasNext() {
+		return PathResult{}, ErrIteratorExhausted
+	}
+
+	res, err := i.result, i.err
+	i.result, i.err, i.ready = PathResult{}, nil, false
+	if err != nil {
+		// Nothing can be pulled once the stream has failed.
+		i.done = true
+	}
+	return res, err
+}
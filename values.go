@@ -0,0 +1,179 @@
+package cilli
+
+import (
+	"strconv"
+	"strings"
+
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+func literal(expr s.PathExpression) interface{} {
+	x, ok := expr.(s.Value)
+	if !ok {
+		return nil
+	}
+
+	return x.Value()
+}
+
+func coerce(value interface{}, t PathArgumentType) (interface{}, error) {
+	switch t {
+	case PATString:
+		return toString(value), nil
+	case PATNumber:
+		if res, ok := toNumber(value); ok {
+			return res, nil
+		}
+		return nil, ErrInvalidArgument
+	case PATBoolean:
+		return toBoolean(value), nil
+	case PATElements:
+		if res, ok := value.([]s.Element); ok {
+			return res, nil
+		}
+		return nil, ErrInvalidArgument
+	}
+	return value, nil
+}
+
+func toString(value interface{}) string {
+	switch x := value.(type) {
+	case string:
+		return x
+	case bool:
+		return strconv.FormatBool(x)
+	case []s.Element:
+		if len(x) > 0 {
+			return x[0].Name()
+		}
+		return ""
+	}
+	if res, ok := toNumber(value); ok {
+		return strconv.FormatFloat(res, 'f', -1, 64)
+	}
+	return ""
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch x := value.(type) {
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	case int:
+		return float64(x), true
+	case int8:
+		return float64(x), true
+	case int16:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case uint:
+		return float64(x), true
+	case uint8:
+		return float64(x), true
+	case uint16:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case bool:
+		if x {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		res, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return res, err == nil
+	}
+	return 0, false
+}
+
+func toBoolean(value interface{}) bool {
+	switch x := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case string:
+		return x != ""
+	case []s.Element:
+		return len(x) > 0
+	}
+	if res, ok := toNumber(value); ok {
+		return res != 0
+	}
+	return true
+}
+
+// compare two values using the comparison expression type. Numbers compare
+// numerically, strings lexically and booleans by equality. Values that can't
+// be compared never match.
+func compare(t s.PathExpressionType, a, b interface{}) bool {
+	res, ok := order(a, b)
+	if !ok {
+		return false
+	}
+
+	switch t {
+	case s.PETEquality:
+		return res == 0
+	case s.PETInequality:
+		return res != 0
+	case s.PETLessThan:
+		return res < 0
+	case s.PETLessThanOrEqualTo:
+		return res <= 0
+	case s.PETGreaterThan:
+		return res > 0
+	case s.PETGreaterThanOrEqualTo:
+		return res >= 0
+	}
+	return false
+}
+
+func order(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			if x == y {
+				return 0, true
+			}
+			if y {
+				return -1, true
+			}
+			return 1, true
+		}
+	case []s.Element:
+		return 0, false
+	}
+
+	if _, ok := b.([]s.Element); ok {
+		return 0, false
+	}
+
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			case x == y:
+				return 0, true
+			}
+		}
+	}
+	return 0, false
+}
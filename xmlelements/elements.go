@@ -0,0 +1,199 @@
+// Package xmlelements exposes XML read with encoding/xml as elements, so that
+// it can be queried with a path.
+package xmlelements
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+
+	s "github.com/SimonRichardson/cilli/selectors"
+)
+
+// TextAttribute is the pseudo-attribute holding the text directly within an
+// element, with surrounding whitespace removed. Elements without any text
+// don't have it, and an attribute of the same name takes precedence.
+const TextAttribute = "_text"
+
+type element struct {
+	name     string
+	attrs    map[string]string
+	names    []string
+	text     string
+	children []s.Element
+	parent   *element
+}
+
+func (e *element) Name() string {
+	return e.name
+}
+
+func (e *element) Children() []s.Element {
+	return e.children
+}
+
+func (e *element) Parent() s.Element {
+	if e.parent == nil {
+		return nil
+	}
+	return e.parent
+}
+
+func (e *element) Attribute(name string) (interface{}, bool) {
+	if value, ok := e.attrs[name]; ok {
+		return value, true
+	}
+	if text := strings.TrimSpace(e.text); name == TextAttribute && text != "" {
+		return text, true
+	}
+	return nil, false
+}
+
+// Attributes returns the names of the attributes in document order, followed
+// by TextAttribute when the element holds any text.
+func (e *element) Attributes() []string {
+	res := append([]string{}, e.names...)
+	if _, ok := e.attrs[TextAttribute]; !ok && strings.TrimSpace(e.text) != "" {
+		res = append(res, TextAttribute)
+	}
+	return res
+}
+
+// Reader reads elements from XML. By default elements and attributes are named
+// by their local name, qualified names are written as {space}local, which
+// can be used as a quoted step such as /"{http://www.w3.org/2005/Atom}feed".
+// Attributes of an element that share a local name are always named by their
+// qualified name, so that neither is lost.
+type Reader struct {
+	decoder   *xml.Decoder
+	qualified bool
+	open      []*element
+	capturing int
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		decoder: xml.NewDecoder(r),
+		open:    []*element{{}},
+	}
+}
+
+// Qualified names elements and attributes within a namespace by their
+// qualified name.
+func (r *Reader) Qualified() *Reader {
+	r.qualified = true
+	return r
+}
+
+// Document reads the whole of the next document. The element returned is the
+// document itself, so the root element is its only child.
+func (r *Reader) Document() (s.Element, error) {
+	return r.read(func(*element) bool {
+		return true
+	})
+}
+
+// Next streams the document, only holding one element with the given name in
+// memory at a time. The element returned is a copy of the document holding
+// just that element and its ancestors, so a path from the root of the document
+// still selects it, whilst indexes and siblings only see that one element and
+// the ancestors hold no text.
+// Elements nested within an element that's read are not returned again. At
+// the end of the input io.EOF is returned.
+func (r *Reader) Next(name string) (s.Element, error) {
+	return r.read(func(e *element) bool {
+		return e.name == name
+	})
+}
+
+func (r *Reader) read(match func(*element) bool) (s.Element, error) {
+	for {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		top := r.open[len(r.open)-1]
+		switch x := token.(type) {
+		case xml.StartElement:
+			child := r.element(x, top)
+			if r.capturing > 0 {
+				top.children = append(top.children, child)
+				r.capturing++
+			} else if match(child) {
+				r.capturing++
+			}
+			r.open = append(r.open, child)
+		case xml.EndElement:
+			r.open = r.open[:len(r.open)-1]
+			if r.capturing > 0 {
+				if r.capturing--; r.capturing == 0 {
+					return r.document(top), nil
+				}
+			}
+		case xml.CharData:
+			if r.capturing > 0 {
+				top.text += string(x)
+			}
+		}
+	}
+}
+
+func (r *Reader) element(start xml.StartElement, parent *element) *element {
+	res := &element{
+		name:   r.qualify(start.Name),
+		attrs:  make(map[string]string, len(start.Attr)),
+		parent: parent,
+	}
+	var (
+		attrs  []xml.Attr
+		locals = make(map[string]int, len(start.Attr))
+	)
+	for _, v := range start.Attr {
+		if v.Name.Space == "xmlns" || (v.Name.Space == "" && v.Name.Local == "xmlns") {
+			continue
+		}
+		attrs = append(attrs, v)
+		locals[v.Name.Local]++
+	}
+	for _, v := range attrs {
+		name := r.qualify(v.Name)
+		if locals[v.Name.Local] > 1 && v.Name.Space != "" {
+			name = "{" + v.Name.Space + "}" + v.Name.Local
+		}
+		res.attrs[name] = v.Value
+		res.names = append(res.names, name)
+	}
+	return res
+}
+
+func (r *Reader) qualify(name xml.Name) string {
+	if r.qualified && name.Space != "" {
+		return "{" + name.Space + "}" + name.Local
+	}
+	return name.Local
+}
+
+// document copies the open elements, dropping any children that were read
+// before, to hold the element.
+func (r *Reader) document(e *element) *element {
+	var root, parent *element
+	for _, v := range r.open {
+		res := &element{
+			name:   v.name,
+			attrs:  v.attrs,
+			names:  v.names,
+			text:   v.text,
+			parent: parent,
+		}
+		if parent == nil {
+			root = res
+		} else {
+			parent.children = []s.Element{res}
+		}
+		parent = res
+	}
+	parent.children = []s.Element{e}
+	e.parent = parent
+	return root
+}
@@ -0,0 +1,109 @@
+package xmlelements_test
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SimonRichardson/cilli"
+	s "github.com/SimonRichardson/cilli/selectors"
+	"github.com/SimonRichardson/cilli/xmlelements"
+)
+
+const xmlDocument = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:media="http://search.yahoo.com/mrss/">
+	<title>Events</title>
+	<entry lang="en" media:type="image"><title>Launch</title></entry>
+	<entry lang="fr"><title>Lancement</title></entry>
+	<entry lang="en"><title> Landing </title></entry>
+</feed>`
+
+func execute(t *testing.T, dsl string, element s.Element) []interface{} {
+	lex := cilli.NewPathLexer(dsl).With(s.PathTokenTypes())
+	expr, err := cilli.NewPathParser(lex.Iter()).ParseExpression()
+	if err != nil {
+		t.Fatalf("%s: %v", dsl, err)
+	}
+	res, err := cilli.NewPath(expr).ExecuteValues(element)
+	if err != nil {
+		t.Fatalf("%s: %v", dsl, err)
+	}
+	return res.Values()
+}
+
+func Test_XMLElements(t *testing.T) {
+	for _, test := range []struct {
+		dsl       string
+		qualified bool
+		values    []interface{}
+	}{
+		{dsl: `/feed/entry.(@lang=="en")/title/@_text`, values: []interface{}{"Launch", "Landing"}},
+		{dsl: `/feed/entry[1]/title/@_text`, values: []interface{}{"Lancement"}},
+		{dsl: `/feed/entry.(@type=="image")/@*`, values: []interface{}{"en", "image"}},
+		{dsl: `/feed/title/@_text`, values: []interface{}{"Events"}},
+		{
+			dsl:       `/"{http://www.w3.org/2005/Atom}feed"/"{http://www.w3.org/2005/Atom}entry"/@*`,
+			qualified: true,
+			values:    []interface{}{"en", "image", "fr", "en"},
+		},
+		{dsl: `/feed`, qualified: true, values: []interface{}{}},
+	} {
+		reader := xmlelements.NewReader(strings.NewReader(xmlDocument))
+		if test.qualified {
+			reader = reader.Qualified()
+		}
+		document, err := reader.Document()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res := execute(t, test.dsl, document); !reflect.DeepEqual(res, test.values) {
+			t.Errorf("%s: expected %v, got %v", test.dsl, test.values, res)
+		}
+	}
+}
+
+func Test_XMLElementsStream(t *testing.T) {
+	var (
+		reader = xmlelements.NewReader(strings.NewReader(xmlDocument))
+		titles []interface{}
+	)
+	for {
+		document, err := reader.Next("entry")
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		titles = append(titles, execute(t, `/feed/entry.(@lang=="en")/title/@_text`, document)...)
+	}
+	if expected := []interface{}{"Launch", "Landing"}; !reflect.DeepEqual(titles, expected) {
+		t.Errorf("expected %v, got %v", expected, titles)
+	}
+}
+
+func Test_XMLElementsAttributes(t *testing.T) {
+	const source = `<item xmlns:a="urn:a" xmlns:b="urn:b" a:id="1" b:id="2" lang="en"><empty> </empty></item>`
+
+	document, err := xmlelements.NewReader(strings.NewReader(source)).Document()
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := document.Children()[0].(s.Attributes)
+	if expected, names := []string{"{urn:a}id", "{urn:b}id", "lang"}, item.Attributes(); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+	for name, expected := range map[string]interface{}{"{urn:a}id": "1", "{urn:b}id": "2", "lang": "en"} {
+		if value, ok := item.Attribute(name); !ok || value != expected {
+			t.Errorf("%s: expected %v, got %v", name, expected, value)
+		}
+	}
+
+	empty := document.Children()[0].Children()[0].(s.Attributes)
+	if names := empty.Attributes(); len(names) != 0 {
+		t.Errorf("expected no attributes, got %v", names)
+	}
+	if value, ok := empty.Attribute(xmlelements.TextAttribute); ok {
+		t.Errorf("expected no text, got %q", value)
+	}
+}